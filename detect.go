@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// Init systems the spec has no value for, `DetectInitSystem()` reports them
+const (
+	InitOpenRC  = spec.InitType("openrc")
+	InitRunit   = spec.InitType("runit")
+	InitBusybox = spec.InitType("busybox")
+	InitLaunchd = spec.InitType("launchd")
+	InitSCM     = spec.InitType("scm")
+)
+
+// InitSystemReport - what `DetectInitSystem()` found and what made it decide
+type InitSystemReport struct {
+	Type                 spec.InitType `json:"type"`
+	Evidence             []string      `json:"evidence,omitempty"`   // ex: `/run/systemd/system exists`, `/proc/1/exe -> /sbin/openrc-init`
+	UserSystemdReachable bool          `json:"userSystemdReachable"` // `systemctl --user` has a manager to talk to
+	InContainer          bool          `json:"inContainer"`
+}
+
+// DetectInitSystem - finds out which init system runs this machine, `Type` is `spec.InitUknown` if it can't tell
+func DetectInitSystem() InitSystemReport {
+	return detectInitSystem(context.Background(), "/", NewDefaultExecutor())
+}
+
+func (thisRef *InitSystemReport) addEvidence(evidence string) {
+	thisRef.Evidence = append(thisRef.Evidence, evidence)
+}
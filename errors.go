@@ -1,7 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
 )
 
 // ErrServiceDoesNotExist -
@@ -12,3 +16,177 @@ var ErrServiceConfigError = errors.New("Service config error")
 
 // ErrServiceUnsupportedRequest -
 var ErrServiceUnsupportedRequest = errors.New("Service unsupported request")
+
+// ErrServicePermissionDenied -
+var ErrServicePermissionDenied = errors.New("Service permission denied")
+
+// ErrServiceAlreadyExists -
+var ErrServiceAlreadyExists = errors.New("Service already exists")
+
+// ErrServiceTimeout -
+var ErrServiceTimeout = errors.New("Service timeout")
+
+// ErrInitSystemNotDetected -
+var ErrInitSystemNotDetected = errors.New("Init system not detected")
+
+// ErrUserManagerUnreachable - there is no per-user service manager to talk to, ex: the user is not logged in
+var ErrUserManagerUnreachable = errors.New("User service manager unreachable")
+
+// OperationError - a call to the init system's tooling that failed, `errors.Is()` matches it against
+// the `Err...` value in `Kind` and `errors.As()` reaches the underlying error, ex: `*exec.ExitError`
+type OperationError struct {
+	Backend   string   `json:"backend"`          // ex: systemd, systemv, upstart
+	Operation string   `json:"operation"`        // ex: start, stop, enable
+	Command   []string `json:"command"`          // the command line that ran
+	ExitCode  int      `json:"exitCode"`         // -1 if the command did not run to completion
+	Output    string   `json:"output,omitempty"` // combined stdout and stderr
+	Kind      error    `json:"-"`                // one of the `Err...` values, nil if the failure could not be classified
+	Err       error    `json:"-"`                // the underlying error
+}
+
+func (thisRef *OperationError) Error() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s: %s: `%s` failed", thisRef.Backend, thisRef.Operation, strings.Join(thisRef.Command, " ")))
+
+	if thisRef.ExitCode != -1 {
+		sb.WriteString(fmt.Sprintf(" with exit code %d", thisRef.ExitCode))
+	} else if thisRef.Err != nil {
+		sb.WriteString(fmt.Sprintf(": %s", thisRef.Err.Error()))
+	}
+
+	if output := strings.TrimSpace(thisRef.Output); len(output) > 0 {
+		sb.WriteString(fmt.Sprintf(": %s", output))
+	}
+
+	return sb.String()
+}
+
+// Unwrap - the underlying error
+func (thisRef *OperationError) Unwrap() error {
+	return thisRef.Err
+}
+
+// Is - matches the `Err...` value the failure was classified as
+func (thisRef *OperationError) Is(target error) bool {
+	return thisRef.Kind != nil && thisRef.Kind == target
+}
+
+// newOperationError - wraps a failed command, nil if `err` is nil, context errors are returned as they are
+func newOperationError(backend string, operation string, command []string, output string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	return &OperationError{
+		Backend:   backend,
+		Operation: operation,
+		Command:   command,
+		ExitCode:  exitCode,
+		Output:    output,
+		Kind:      classifyOutput(output, err),
+		Err:       err,
+	}
+}
+
+// operationFromArgs - the first argument that is not a flag, ex: `--user start foo` is a start
+func operationFromArgs(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+
+	return ""
+}
+
+// reclassify - for callers that know better than `classifyOutput()` what a failure means
+func reclassify(err error, kind error) error {
+	var operationErr *OperationError
+	if !errors.As(err, &operationErr) {
+		return kind
+	}
+
+	result := *operationErr
+	result.Kind = kind
+
+	return &result
+}
+
+// ValidationError - the init system rejected a unit before it was installed, `errors.Is()` matches it
+// against `ErrServiceConfigError`
+type ValidationError struct {
+	Backend     string       `json:"backend"`          // ex: systemd
+	Unit        string       `json:"unit"`             // ex: nginx.service
+	Diagnostics []Diagnostic `json:"diagnostics"`      // what is wrong, in the order it was reported
+	Output      string       `json:"output,omitempty"` // what the validator printed, as is
+}
+
+// Diagnostic - a single problem found in a unit
+type Diagnostic struct {
+	Line    int    `json:"line,omitempty"` // 1 based, zero if it is about the unit as a whole
+	Message string `json:"message"`        // ex: Unknown key name 'ExecStar' in section 'Service', ignoring.
+}
+
+func (thisRef *ValidationError) Error() string {
+	messages := []string{}
+	for _, diagnostic := range thisRef.Diagnostics {
+		if diagnostic.Line > 0 {
+			messages = append(messages, fmt.Sprintf("line %d: %s", diagnostic.Line, diagnostic.Message))
+		} else {
+			messages = append(messages, diagnostic.Message)
+		}
+	}
+
+	return fmt.Sprintf("%s: %s is not valid: %s", thisRef.Backend, thisRef.Unit, strings.Join(messages, "; "))
+}
+
+// Is - a unit that does not validate is a config error
+func (thisRef *ValidationError) Is(target error) bool {
+	return target == ErrServiceConfigError
+}
+
+// errMasked - what enabling a masked service returns
+func errMasked(name string) error {
+	return fmt.Errorf("%w: %s is masked, unmask it first", ErrServiceUnsupportedRequest, name)
+}
+
+// outputClassifiers - substrings the init tools print for each failure kind, checked in order
+var outputClassifiers = []struct {
+	kind    error
+	markers []string
+}{
+	{ErrServicePermissionDenied, []string{"Access denied", "Permission denied", "Interactive authentication required", "Operation not permitted", "must be root", "must be superuser"}},
+	{ErrInitSystemNotDetected, []string{"has not been booted with systemd", "Unable to connect to Upstart"}},
+	{ErrServiceDoesNotExist, []string{"not found", "does not exist", "not loaded", "could not be found", "No such file or directory", "Unknown job", "unrecognized service"}},
+	{ErrServiceAlreadyExists, []string{"already exists", "File exists"}},
+	{ErrServiceTimeout, []string{"timed out", "Timeout"}},
+	{ErrServiceUnsupportedRequest, []string{"not applicable", "unknown directive"}},
+	{ErrServiceConfigError, []string{"bad unit file setting", "Bad message"}},
+}
+
+func classifyOutput(output string, err error) error {
+	for _, classifier := range outputClassifiers {
+		for _, marker := range classifier.markers {
+			if strings.Contains(output, marker) {
+				return classifier.kind
+			}
+		}
+	}
+
+	// the tool itself could not be run
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrInitSystemNotDetected
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func TestOperationErrorKinds(t *testing.T) {
+	for _, testCase := range []struct {
+		backend string
+		output  string
+		err     error
+		kind    error
+	}{
+		{"systemd", "Failed to stop missing.service: Unit missing.service not loaded.\n", errors.New("exit status 5"), ErrServiceDoesNotExist},
+		{"systemv", "missing: unrecognized service\n", errors.New("exit status 1"), ErrServiceDoesNotExist},
+		{"upstart", "initctl: Unknown job: missing\n", errors.New("exit status 1"), ErrServiceDoesNotExist},
+		{"systemd", "Failed to start daemon.service: Access denied\n", errors.New("exit status 4"), ErrServicePermissionDenied},
+		{"systemd", "System has not been booted with systemd as init system (PID 1). Can't operate.\n", errors.New("exit status 1"), ErrInitSystemNotDetected},
+		{"upstart", "", &exec.Error{Name: "initctl", Err: exec.ErrNotFound}, ErrInitSystemNotDetected},
+		{"systemv", "something odd happened\n", errors.New("exit status 1"), nil},
+	} {
+		err := newOperationError(testCase.backend, "stop", []string{"stop", "missing"}, testCase.output, testCase.err)
+
+		var operationError *OperationError
+		if !errors.As(err, &operationError) {
+			t.Errorf("%q: expected an OperationError, got %T", testCase.output, err)
+			continue
+		}
+		if operationError.Kind != testCase.kind {
+			t.Errorf("%q: expected %v, got %v", testCase.output, testCase.kind, operationError.Kind)
+		}
+		if testCase.kind != nil && !errors.Is(err, testCase.kind) {
+			t.Errorf("%q: expected errors.Is() to match %v", testCase.output, testCase.kind)
+		}
+		if !errors.Is(err, testCase.err) {
+			t.Errorf("%q: expected the underlying error reachable", testCase.output)
+		}
+		if operationError.Backend != testCase.backend || operationError.Output != testCase.output || operationError.ExitCode != -1 {
+			t.Errorf("%q: unexpected OperationError: %+v", testCase.output, operationError)
+		}
+	}
+}
+
+func TestOperationErrorPassThrough(t *testing.T) {
+	if err := newOperationError("systemd", "stop", []string{"stop", "daemon"}, "", nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	for _, ctxErr := range []error{context.Canceled, context.DeadlineExceeded} {
+		if err := newOperationError("systemd", "stop", []string{"stop", "daemon"}, "", ctxErr); err != ctxErr {
+			t.Errorf("expected %v as it is, got %v", ctxErr, err)
+		}
+	}
+
+	// a caller that knows better keeps the rest of the failure
+	err := reclassify(newOperationError("systemd", "is-enabled", []string{"is-enabled", "daemon"}, "masked\n", errors.New("exit status 1")), ErrServiceUnsupportedRequest)
+	if !errors.Is(err, ErrServiceUnsupportedRequest) || fmt.Sprint(err) != "systemd: is-enabled: `is-enabled daemon` failed: exit status 1: masked" {
+		t.Errorf("unexpected reclassified error: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/codemodify/systemkit-service/helpers"
+)
+
+// Executor - runs the init system's tooling on behalf of a Service, ex: `systemctl`, `service`, `initctl`.
+// Implementations return the combined stdout and stderr and should kill the command when `ctx` is done.
+type Executor interface {
+	Exec(ctx context.Context, name string, args ...string) (string, error)
+}
+
+// ExecutorFunc - lets a plain function be an Executor, ex: to route every command through sudo
+//
+//	service.WithExecutor(service.ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+//		return service.NewDefaultExecutor().Exec(ctx, "sudo", append([]string{"-n", name}, args...)...)
+//	}))
+type ExecutorFunc func(ctx context.Context, name string, args ...string) (string, error)
+
+// Exec -
+func (thisRef ExecutorFunc) Exec(ctx context.Context, name string, args ...string) (string, error) {
+	return thisRef(ctx, name, args...)
+}
+
+// StreamingExecutor - an Executor that can also hand out a command's stdout while it runs, ex: `journalctl --follow`.
+// The command is killed when `ctx` is done, closing the reader kills it and waits for it to exit.
+// If the command fails, the reader returns its error instead of `io.EOF`.
+type StreamingExecutor interface {
+	Executor
+
+	ExecStream(ctx context.Context, name string, args ...string) (io.ReadCloser, error)
+}
+
+// NewDefaultExecutor - runs commands on the local machine with `os/exec`
+func NewDefaultExecutor() Executor {
+	return &defaultExecutor{}
+}
+
+type defaultExecutor struct{}
+
+func (thisRef defaultExecutor) Exec(ctx context.Context, name string, args ...string) (string, error) {
+	return helpers.ExecWithArgsContext(ctx, name, args...)
+}
+
+func (thisRef defaultExecutor) ExecStream(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return &commandReader{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// commandReader - stdout of a running command
+type commandReader struct {
+	io.ReadCloser
+	cmd     *exec.Cmd
+	waited  sync.Once
+	waitErr error
+}
+
+// Read - once all of stdout is read, a command that failed reports how instead of `io.EOF`
+func (thisRef *commandReader) Read(p []byte) (int, error) {
+	n, err := thisRef.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := thisRef.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+func (thisRef *commandReader) Close() error {
+	thisRef.cmd.Process.Kill()
+	thisRef.wait()
+
+	return nil
+}
+
+func (thisRef *commandReader) wait() error {
+	thisRef.waited.Do(func() {
+		thisRef.waitErr = thisRef.cmd.Wait()
+	})
+
+	return thisRef.waitErr
+}
@@ -10,5 +10,6 @@ require (
 	github.com/codemodify/systemkit-service-encoders-systemv v1.9.3
 	github.com/codemodify/systemkit-service-encoders-upstart v1.9.3
 	github.com/codemodify/systemkit-service-spec v1.9.3
+	github.com/godbus/dbus/v5 v5.0.6
 	golang.org/x/sys v0.0.0-20210123231150-1d476976d117
 )
@@ -1,7 +1,9 @@
 package helpers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"os/user"
@@ -16,7 +18,7 @@ func AsJSONString(i interface{}) string {
 }
 
 func Is(err1 error, err2 error) bool {
-	if err1 == err2 {
+	if err1 == err2 || errors.Is(err1, err2) {
 		return true
 	}
 
@@ -28,10 +30,22 @@ func Is(err1 error, err2 error) bool {
 }
 
 func ExecWithArgs(name string, args ...string) (out string, err error) {
-	output, err := exec.Command(name, args...).CombinedOutput()
-	return string(output), err
+	return ExecWithArgsContext(context.Background(), name, args...)
 }
 
+// ExecWithArgsContext - same as ExecWithArgs, the process is killed when `ctx` is done and `ctx.Err()` is returned
+func ExecWithArgsContext(ctx context.Context, name string, args ...string) (out string, err error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if ctx.Err() != nil {
+		return string(output), ctx.Err()
+	}
+
+	return string(output), err
+}
 
 func IsRoot() bool {
 	u, err := user.Current()
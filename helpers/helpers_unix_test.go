@@ -0,0 +1,66 @@
+// +build !windows
+
+package helpers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecWithArgsContextCancelKillsCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemkit-helpers")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pidFile := filepath.Join(dir, "sleeper.pid")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := ExecWithArgsContext(ctx, "sh", "-c", "echo $$ > "+pidFile+"; exec sleep 30")
+		done <- err
+	}()
+
+	pid := 0
+	for deadline := time.Now().Add(5 * time.Second); pid <= 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		pidAsString, _ := ioutil.ReadFile(pidFile)
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(pidAsString)))
+	}
+	if pid <= 0 {
+		cancel()
+		t.Fatalf("the command never ran")
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("still waiting for the command after cancelling")
+	}
+
+	if err := syscall.Kill(pid, 0); err != syscall.ESRCH {
+		t.Errorf("expected the command killed, got %v", err)
+	}
+}
+
+func TestExecWithArgsContextAlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ExecWithArgsContext(ctx, "true"); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// instanceSpecifiers - replaced with the instance name in the template's fields, as systemd does with `%i` and `%I`
+var instanceSpecifiers = []string{"%i", "%I"}
+
+// isTemplateName - templates are named like systemd's, ex: `worker@`
+func isTemplateName(name string) bool {
+	return strings.HasSuffix(name, "@") && strings.Count(name, "@") == 1
+}
+
+// splitInstanceName - ex: `worker@tenantA` is the instance `tenantA` of the template `worker@`
+func splitInstanceName(name string) (string, string, bool) {
+	index := strings.Index(name, "@")
+	if index <= 0 || index >= len(name)-1 {
+		return "", "", false
+	}
+
+	return name[:index+1], name[index+1:], true
+}
+
+// instanceName - the name of an instance of `template`, the instance ends up in file names and command lines
+func instanceName(template string, instance string) (string, error) {
+	if !isTemplateName(template) {
+		return "", fmt.Errorf("%w: %s is not a template, template names end in @", ErrServiceUnsupportedRequest, template)
+	}
+
+	if len(instance) <= 0 || strings.ContainsAny(instance, "@/\\ \t\n'\"$`") {
+		return "", fmt.Errorf("%w: invalid instance name %q", ErrServiceConfigError, instance)
+	}
+
+	return template + instance, nil
+}
+
+// instanceEnvVar - the instance name in the environment of instances of emulated templates, `%i` as a variable
+const instanceEnvVar = "INSTANCE"
+
+// instanceSERVICE - the template's description with the instance filled in, for init systems that have no templates.
+// `$INSTANCE` is set unless the template sets it.
+func instanceSERVICE(template spec.SERVICE, instance string) spec.SERVICE {
+	replace := func(value string) string {
+		for _, specifier := range instanceSpecifiers {
+			value = strings.Replace(value, specifier, instance, -1)
+		}
+		return value
+	}
+
+	result := template
+	result.Name = template.Name + instance
+	result.Description = replace(template.Description)
+	result.Executable = replace(template.Executable)
+	result.WorkingDirectory = replace(template.WorkingDirectory)
+	result.Logging.StdOut.Value = replace(template.Logging.StdOut.Value)
+	result.Logging.StdErr.Value = replace(template.Logging.StdErr.Value)
+
+	result.Args = []string{}
+	for _, arg := range template.Args {
+		result.Args = append(result.Args, replace(arg))
+	}
+
+	result.Environment = map[string]string{}
+	for key, value := range template.Environment {
+		result.Environment[key] = replace(value)
+	}
+	if _, ok := result.Environment[instanceEnvVar]; !ok {
+		result.Environment[instanceEnvVar] = instance
+	}
+
+	return result
+}
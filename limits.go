@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// Limits - caps on what a service can use, zero fields are not limited.
+// SysV, Upstart and cron only have per-process limits, there `MemoryMax` caps the address space of each process
+// and `TasksMax` the processes of the user the service runs as.
+type Limits struct {
+	MemoryMax uint64 `json:"memoryMax,omitempty"` // bytes
+	CPUQuota  int    `json:"cpuQuota,omitempty"`  // percent of one CPU, ex: 150 is one and a half
+	TasksMax  uint64 `json:"tasksMax,omitempty"`  // processes and threads
+	OpenFiles uint64 `json:"openFiles,omitempty"` // file descriptors
+}
+
+// limitsHonoredBy - the fields of Limits each backend applies, setting any other one logs a warning
+var limitsHonoredBy = map[string][]string{
+	string(spec.InitSystemd): {"MemoryMax", "CPUQuota", "TasksMax", "OpenFiles"},
+	string(spec.InitSystemV): {"MemoryMax", "TasksMax", "OpenFiles"},
+	string(spec.InitUpstart): {"MemoryMax", "TasksMax", "OpenFiles"},
+	"cron":                   {"MemoryMax", "TasksMax", "OpenFiles"},
+}
+
+// limitsApproximatedBy - the fields of Limits each backend applies as something close but not the same, setting one logs a warning
+var limitsApproximatedBy = map[string][]string{
+	string(spec.InitSystemV): {"MemoryMax"},
+	string(spec.InitUpstart): {"MemoryMax"},
+	"cron":                   {"MemoryMax"},
+}
+
+func (thisRef Limits) validate() error {
+	if thisRef.CPUQuota < 0 {
+		return fmt.Errorf("%w: CPU quota %d%%, expected a positive percentage", ErrServiceConfigError, thisRef.CPUQuota)
+	}
+
+	return nil
+}
+
+// setFields - the names of the fields that are not zero
+func (thisRef Limits) setFields() []string {
+	result := []string{}
+
+	for _, field := range []struct {
+		name  string
+		isSet bool
+	}{
+		{"MemoryMax", thisRef.MemoryMax > 0},
+		{"CPUQuota", thisRef.CPUQuota > 0},
+		{"TasksMax", thisRef.TasksMax > 0},
+		{"OpenFiles", thisRef.OpenFiles > 0},
+	} {
+		if field.isSet {
+			result = append(result, field.name)
+		}
+	}
+
+	return result
+}
+
+// warnUnhonored - logs the fields `backend` leaves out, the service is installed without them,
+// and the ones it approximates, ex: `MemoryMax` as the address space of each process on SysV
+func (thisRef Limits) warnUnhonored(backend string) {
+	for _, warning := range thisRef.warnings(backend) {
+		logging.Warningf("%s", warning)
+	}
+}
+
+// warnings - what `warnUnhonored()` logs
+func (thisRef Limits) warnings(backend string) []string {
+	result := []string{}
+	for _, field := range thisRef.setFields() {
+		if !containsString(limitsHonoredBy[backend], field) {
+			result = append(result, fmt.Sprintf("%s can't limit %s, the service runs without this limit", backend, field))
+		} else if containsString(limitsApproximatedBy[backend], field) {
+			result = append(result, fmt.Sprintf("%s approximates %s with a per-process limit, the service as a whole may use more", backend, field))
+		}
+	}
+
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
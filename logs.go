@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// logTimeLayouts - timestamps a log line may start with, ex: Go's `log` package prints `2006/01/02 15:04:05`
+var logTimeLayouts = []struct {
+	layout string
+	fields int
+}{
+	{time.RFC3339Nano, 1},
+	{"2006-01-02 15:04:05", 2},
+	{"2006/01/02 15:04:05", 2},
+}
+
+// logFollowInterval - how often `followLogFiles()` looks for new lines, rotation and truncation
+var logFollowInterval = 250 * time.Millisecond
+
+// logFilePaths - where `logging` sends stdout and stderr, a default is used for `UseDefault` or when there is no value
+func logFilePaths(logging spec.LoggingConfig, defaultStdOut string, defaultStdErr string) []string {
+	result := []string{}
+
+	for _, item := range []struct {
+		config       spec.LoggingConfigOut
+		defaultValue string
+	}{
+		{logging.StdOut, defaultStdOut},
+		{logging.StdErr, defaultStdErr},
+	} {
+		if item.config.Disabled {
+			continue
+		}
+
+		path := item.config.Value
+		if item.config.UseDefault || len(path) <= 0 {
+			path = item.defaultValue
+		}
+
+		// INFO: stdout and stderr often go to the same file
+		if len(path) > 0 && (len(result) <= 0 || result[0] != path) {
+			result = append(result, path)
+		}
+	}
+
+	return result
+}
+
+// readLogFiles - the last `limit` lines of the files since `since`, oldest first, zero values mean everything.
+// Lines without a timestamp of their own take the one of the line before, ex: a stack trace,
+// if a file has none at all `since` can only skip it by its modification time.
+func readLogFiles(paths []string, since time.Time, limit int) ([]LogLine, error) {
+	type sortableLine struct {
+		line    LogLine
+		sortKey time.Time
+	}
+
+	lines := []sortableLine{}
+
+	for _, path := range paths {
+		fileInfo, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			// INFO: nothing was logged yet
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		fileContent, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastTime *time.Time
+		for _, text := range strings.Split(strings.TrimRight(string(fileContent), "\n"), "\n") {
+			text = strings.TrimRight(text, "\r")
+			if len(text) <= 0 {
+				continue
+			}
+
+			if lineTime := parseLogLineTime(text); lineTime != nil {
+				lastTime = lineTime
+			}
+
+			sortKey := fileInfo.ModTime()
+			if lastTime != nil {
+				sortKey = *lastTime
+			}
+
+			if !since.IsZero() && sortKey.Before(since) {
+				continue
+			}
+
+			lines = append(lines, sortableLine{
+				line:    LogLine{Time: lastTime, Source: path, Text: text},
+				sortKey: sortKey,
+			})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].sortKey.Before(lines[j].sortKey)
+	})
+
+	if limit > 0 && len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+
+	result := []LogLine{}
+	for _, line := range lines {
+		result = append(result, line.line)
+	}
+
+	return result, nil
+}
+
+// parseLogLineTime - the timestamp the line starts with, nil if it has none
+func parseLogLineTime(text string) *time.Time {
+	fields := strings.Fields(text)
+
+	for _, candidate := range logTimeLayouts {
+		if len(fields) < candidate.fields {
+			continue
+		}
+
+		// INFO: fractional seconds are accepted even if the layout has none
+		value := strings.Join(fields[:candidate.fields], " ")
+		if result, err := time.ParseInLocation(candidate.layout, value, time.Local); err == nil {
+			return &result
+		}
+	}
+
+	return nil
+}
+
+// followLogFiles - sends the lines appended to the files from now on until `ctx` is done, then closes the channel.
+// A file that is replaced, ex: by logrotate, is read to its end and then followed under the same name,
+// a file that shrinks was truncated and is read again from the start.
+func followLogFiles(ctx context.Context, paths []string) <-chan LogLine {
+	result := make(chan LogLine, 64)
+
+	followers := []*logFileFollower{}
+	for _, path := range paths {
+		follower := &logFileFollower{path: path}
+		follower.open(true)
+		followers = append(followers, follower)
+	}
+
+	go func() {
+		defer close(result)
+		defer func() {
+			for _, follower := range followers {
+				follower.close()
+			}
+		}()
+
+		ticker := time.NewTicker(logFollowInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, follower := range followers {
+				for _, line := range follower.poll() {
+					select {
+					case result <- line:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return result
+}
+
+// logFileFollower - `tail -F` for a single file
+type logFileFollower struct {
+	path     string
+	file     *os.File
+	fileInfo os.FileInfo
+	offset   int64
+	partial  string
+	lastTime *time.Time
+}
+
+// open - `atEnd` skips what is already in the file, a file that shows up later is read from the start
+func (thisRef *logFileFollower) open(atEnd bool) {
+	file, err := os.Open(thisRef.path)
+	if err != nil {
+		return
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	thisRef.file = file
+	thisRef.fileInfo = fileInfo
+	thisRef.offset = 0
+	thisRef.partial = ""
+	if atEnd {
+		thisRef.offset = fileInfo.Size()
+	}
+}
+
+func (thisRef *logFileFollower) close() {
+	if thisRef.file != nil {
+		thisRef.file.Close()
+		thisRef.file = nil
+	}
+}
+
+func (thisRef *logFileFollower) poll() []LogLine {
+	if thisRef.file == nil {
+		thisRef.open(false)
+		if thisRef.file == nil {
+			return nil
+		}
+	}
+
+	// 1. rotated, what was written to the old file before the switch still counts
+	if currentInfo, err := os.Stat(thisRef.path); err != nil || !os.SameFile(currentInfo, thisRef.fileInfo) {
+		lines := thisRef.read()
+		lines = append(lines, thisRef.flush()...)
+		thisRef.close()
+		thisRef.open(false)
+
+		if thisRef.file != nil {
+			lines = append(lines, thisRef.read()...)
+		}
+
+		return lines
+	}
+
+	// 2. truncated
+	if currentInfo, err := thisRef.file.Stat(); err == nil && currentInfo.Size() < thisRef.offset {
+		thisRef.offset = 0
+		thisRef.partial = ""
+	}
+
+	// 3.
+	return thisRef.read()
+}
+
+// read - the complete lines after `offset`, an unfinished last line waits for the rest of it
+func (thisRef *logFileFollower) read() []LogLine {
+	if _, err := thisRef.file.Seek(thisRef.offset, io.SeekStart); err != nil {
+		return nil
+	}
+
+	content, err := ioutil.ReadAll(thisRef.file)
+	if err != nil || len(content) <= 0 {
+		return nil
+	}
+	thisRef.offset += int64(len(content))
+
+	texts := strings.Split(thisRef.partial+string(content), "\n")
+	thisRef.partial = texts[len(texts)-1]
+
+	return thisRef.lines(texts[:len(texts)-1])
+}
+
+// flush - the unfinished last line of a file that won't get any more
+func (thisRef *logFileFollower) flush() []LogLine {
+	if len(thisRef.partial) <= 0 {
+		return nil
+	}
+
+	partial := thisRef.partial
+	thisRef.partial = ""
+
+	return thisRef.lines([]string{partial})
+}
+
+func (thisRef *logFileFollower) lines(texts []string) []LogLine {
+	result := []LogLine{}
+
+	for _, text := range texts {
+		text = strings.TrimRight(text, "\r")
+		if len(text) <= 0 {
+			continue
+		}
+
+		if lineTime := parseLogLineTime(text); lineTime != nil {
+			thisRef.lastTime = lineTime
+		}
+
+		result = append(result, LogLine{Time: thisRef.lastTime, Source: thisRef.path, Text: text})
+	}
+
+	return result
+}
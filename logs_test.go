@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLogFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemkit-logs")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	stdOut := filepath.Join(dir, "worker.log")
+	stdErr := filepath.Join(dir, "worker.err")
+	ioutil.WriteFile(stdOut, []byte("2021/01/26 10:00:00 starting\n2021/01/26 10:00:02 ready\n"), 0644)
+	ioutil.WriteFile(stdErr, []byte("2021-01-26T10:00:01Z panic: boom\ngoroutine 1 [running]:\n"), 0644)
+
+	lines, err := readLogFiles([]string{stdOut, stdErr, filepath.Join(dir, "missing.log")}, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"starting", "panic: boom", "goroutine 1 [running]:", "ready"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %+v", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line.Text, expected[i]) {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], line.Text)
+		}
+		if line.Time == nil {
+			t.Errorf("line %d: expected a time, %q follows a timestamped line", i, line.Text)
+		}
+	}
+	if lines[2].Source != stdErr || lines[2].Time.Unix() != lines[1].Time.Unix() {
+		t.Errorf("expected the stack trace to take the time of the panic, got %+v", lines[2])
+	}
+
+	lines, _ = readLogFiles([]string{stdOut, stdErr}, time.Time{}, 1)
+	if len(lines) != 1 || lines[0].Text != "2021/01/26 10:00:02 ready" {
+		t.Errorf("expected only the last line, got %+v", lines)
+	}
+
+	since := time.Date(2021, 1, 26, 10, 0, 1, 0, time.UTC)
+	lines, _ = readLogFiles([]string{stdErr}, since, 0)
+	if len(lines) != 2 {
+		t.Errorf("expected the lines since %s, got %+v", since, lines)
+	}
+}
+
+func TestFollowLogFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemkit-follow")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(interval time.Duration) { logFollowInterval = interval }(logFollowInterval)
+	logFollowInterval = 10 * time.Millisecond
+
+	path := filepath.Join(dir, "worker.log")
+	ioutil.WriteFile(path, []byte("old line\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := followLogFiles(ctx, []string{path})
+
+	expectLine := func(text string) {
+		select {
+		case line := <-lines:
+			if line.Text != text || line.Source != path {
+				t.Errorf("expected %q, got %+v", text, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", text)
+		}
+	}
+
+	appendLine := func(path string, text string) {
+		file, _ := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		file.WriteString(text)
+		file.Close()
+	}
+
+	appendLine(path, "first")
+	appendLine(path, " line\n")
+	expectLine("first line")
+
+	// rotated, the last line of the old file comes before the new file
+	os.Rename(path, path+".1")
+	appendLine(path+".1", "last before rotation\n")
+	appendLine(path, "after rotation\n")
+	expectLine("last before rotation")
+	expectLine("after rotation")
+
+	// truncated
+	os.Truncate(path, 0)
+	time.Sleep(5 * logFollowInterval)
+	appendLine(path, "after truncation\n")
+	expectLine("after truncation")
+
+	cancel()
+	for range lines {
+	}
+}
@@ -0,0 +1,160 @@
+// Package notify - the service side of systemd's `Type=notify`, implements the sd_notify protocol over `$NOTIFY_SOCKET`
+// so a binary installed with `service.WithNotify()` can tell systemd it is ready and keep its watchdog happy.
+// Outside of systemd `$NOTIFY_SOCKET` is not set and every call is a no-op.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	logging "github.com/codemodify/systemkit-logging"
+)
+
+// States a service can report, see `sd_notify(3)`
+const (
+	StateReady     = "READY=1"
+	StateReloading = "RELOADING=1"
+	StateStopping  = "STOPPING=1"
+	StateWatchdog  = "WATCHDOG=1"
+)
+
+// Send - sends `states` to systemd as a single message, ex: `Send(StateReady, "STATUS=listening on :80")`.
+// Returns false and no error if `$NOTIFY_SOCKET` is not set.
+func Send(states ...string) (bool, error) {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if len(socketPath) <= 0 {
+		return false, nil
+	}
+
+	// INFO: a leading `@` is an abstract socket, Go maps it the same way systemd does
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(states, "\n")))
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Ready - startup is finished, systemd considers the service started from now on
+func Ready() error {
+	return sendOrNil(StateReady)
+}
+
+// Reloading - the service reloads its configuration, call `Ready()` when it is done
+func Reloading() error {
+	return sendOrNil(StateReloading)
+}
+
+// Stopping - the service is shutting down
+func Stopping() error {
+	return sendOrNil(StateStopping)
+}
+
+// Status - a single line `systemctl status` shows, ex: "42 connections"
+func Status(status string) error {
+	return sendOrNil("STATUS=" + strings.Replace(status, "\n", " ", -1))
+}
+
+// Watchdog - pets the watchdog, has to happen within every `WatchdogInterval()`
+func Watchdog() error {
+	return sendOrNil(StateWatchdog)
+}
+
+// WatchdogInterval - `WatchdogSec=` as passed in `$WATCHDOG_USEC`, zero if the watchdog is off
+// or `$WATCHDOG_PID` says it is meant for another process
+func WatchdogInterval() (time.Duration, error) {
+	usecValue := os.Getenv("WATCHDOG_USEC")
+	if len(usecValue) <= 0 {
+		return 0, nil
+	}
+
+	usec, err := strconv.ParseInt(usecValue, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usecValue)
+	}
+
+	if pidValue := os.Getenv("WATCHDOG_PID"); len(pidValue) > 0 {
+		pid, err := strconv.Atoi(pidValue)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q", pidValue)
+		}
+
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// StartWatchdog - pets the watchdog at half of `WatchdogInterval()` until `ctx` is done, a ping that fails is logged.
+// Returns right away with no error if the watchdog is off.
+func StartWatchdog(ctx context.Context) error {
+	return StartWatchdogFunc(ctx, func(err error) {
+		logging.Errorf("notify: watchdog: %v", err)
+	})
+}
+
+// StartWatchdogFunc - `StartWatchdog()` that hands every failed ping to `onError`.
+// It stops pinging once `$NOTIFY_SOCKET` is gone or refuses, systemd is not listening anymore.
+func StartWatchdogFunc(ctx context.Context, onError func(err error)) error {
+	interval, err := WatchdogInterval()
+	if err != nil || interval <= 0 {
+		return err
+	}
+
+	// INFO: half the interval is what systemd recommends, a late tick still makes it in time
+	ticker := time.NewTicker(interval / 2)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := Watchdog()
+				if err == nil {
+					continue
+				}
+
+				onError(err)
+				if isPermanent(err) {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// isPermanent - whether sending again can't succeed, ex: the socket was removed, a full buffer may clear up
+func isPermanent(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ENOENT, syscall.ECONNREFUSED, syscall.EACCES, syscall.EPERM} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sendOrNil(state string) error {
+	_, err := Send(state)
+	return err
+}
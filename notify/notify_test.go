@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	if sent, err := Send(StateReady); sent || err != nil {
+		t.Fatalf("expected a no-op without NOTIFY_SOCKET, got %v, %v", sent, err)
+	}
+
+	dir, err := ioutil.TempDir("", "systemkit-notify")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "notify.sock")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("can't listen on a unix datagram socket: %v", err)
+	}
+	defer listener.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socketPath)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	receive := func() string {
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buffer := make([]byte, 4096)
+		n, err := listener.Read(buffer)
+		if err != nil {
+			t.Fatalf("nothing received: %v", err)
+		}
+		return string(buffer[:n])
+	}
+
+	if err := Ready(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message := receive(); message != "READY=1" {
+		t.Errorf("expected READY=1, got %q", message)
+	}
+
+	if err := Status("two\nlines"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message := receive(); message != "STATUS=two lines" {
+		t.Errorf("expected a single status line, got %q", message)
+	}
+
+	os.Setenv("WATCHDOG_USEC", "40000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	if interval, err := WatchdogInterval(); interval != 40*time.Millisecond || err != nil {
+		t.Fatalf("expected 40ms, got %v, %v", interval, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := StartWatchdog(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message := receive(); message != "WATCHDOG=1" {
+		t.Errorf("expected WATCHDOG=1, got %q", message)
+	}
+
+	os.Setenv("WATCHDOG_PID", "1")
+	if interval, err := WatchdogInterval(); interval != 0 || err != nil {
+		t.Errorf("expected no watchdog for another process, got %v, %v", interval, err)
+	}
+}
+
+func TestWatchdogErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemkit-notify")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// INFO: nothing listens there, like systemd gone away
+	os.Setenv("NOTIFY_SOCKET", filepath.Join(dir, "missing.sock"))
+	os.Setenv("WATCHDOG_USEC", "20000")
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	defer os.Unsetenv("WATCHDOG_USEC")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 10)
+	if err := StartWatchdogFunc(ctx, func(err error) { errs <- err }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if !isPermanent(err) {
+			t.Errorf("expected a missing socket to be permanent, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the failed ping reported")
+	}
+
+	// it stopped, no more pings fail
+	time.Sleep(100 * time.Millisecond)
+	if len(errs) > 0 {
+		t.Errorf("expected the watchdog stopped, got %v", <-errs)
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+	"github.com/codemodify/systemkit-service/helpers"
+)
+
+// Scope - whether a service belongs to the whole machine or to a single user
+type Scope string
+
+// Scope values
+const (
+	ScopeAuto   = Scope("auto")   // user for anyone but root where the init system has per-user services, system elsewhere
+	ScopeSystem = Scope("system") // ex: `/etc/systemd/system`, `systemctl`
+	ScopeUser   = Scope("user")   // ex: `~/.config/systemd/user`, `systemctl --user`
+)
+
+// Option - customizes how a Service is built and how it behaves
+type Option func(*options)
+
+type options struct {
+	enableOnStart bool
+	executor      Executor
+	root          string
+	initType      spec.InitType
+	scope         Scope
+	systemdDBus   bool
+	dbusAddress   string
+	dbusConn      *sharedConnection // the services built with the same options share it
+	socket        *Socket
+	schedule      *Schedule
+	notify        bool
+	watchdog      time.Duration
+	verify        bool
+	linger        bool
+	limits        *Limits
+}
+
+// Socket - what systemd listens on for a socket-activated service, see `systemd.socket(5)`
+type Socket struct {
+	ListenStream       []string `json:"listenStream,omitempty"`       // ex: 8080, 127.0.0.1:8080, /run/app.sock
+	ListenDatagram     []string `json:"listenDatagram,omitempty"`     // ex: 514, /run/app.dgram
+	Accept             bool     `json:"accept,omitempty"`             // a service instance per connection, the unit is installed as `<name>@.service`
+	FileDescriptorName string   `json:"fileDescriptorName,omitempty"` // what the service sees in `$LISTEN_FDNAMES`
+}
+
+// sharedConnection - a connection made on first use and reused after, ex: to systemd over D-Bus
+type sharedConnection struct {
+	mutex sync.Mutex
+	conn  io.Closer
+}
+
+func newOptions(opts []Option) options {
+	result := options{
+		executor: NewDefaultExecutor(),
+		scope:    ScopeAuto,
+		dbusConn: &sharedConnection{},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&result)
+		}
+	}
+
+	return result
+}
+
+// WithEnableOnStart - `Start()` also enables the service at boot and `Stop()` also disables it,
+// this is how the Linux backends behaved before `Enable()` and `Disable()` existed
+func WithEnableOnStart() Option {
+	return func(thisRef *options) {
+		thisRef.enableOnStart = true
+	}
+}
+
+// WithExecutor - runs the init system's tooling through `executor` instead of `os/exec`
+func WithExecutor(executor Executor) Option {
+	return func(thisRef *options) {
+		if executor != nil {
+			thisRef.executor = executor
+		}
+	}
+}
+
+// WithInitType - uses `initType` instead of detecting the init system, an unknown type is `ErrInitSystemNotDetected`.
+// Without it the `SYSTEMKIT_SERVICE_INIT` environment variable is checked before detecting.
+// Only Linux has more than one init system to pick from, the others ignore it.
+func WithInitType(initType spec.InitType) Option {
+	return func(thisRef *options) {
+		thisRef.initType = initType
+	}
+}
+
+// WithScope - manages a system or a user service no matter who runs the code, ex: root managing its own user units.
+// Asking for `ScopeUser` where the init system has no per-user services is `ErrServiceUnsupportedRequest`.
+func WithScope(scope Scope) Option {
+	return func(thisRef *options) {
+		thisRef.scope = scope
+	}
+}
+
+// WithSystemdDBus - the systemd backend talks to `org.freedesktop.systemd1` over D-Bus instead of running `systemctl`.
+// An empty `address` is the system bus, or the session bus for user services.
+func WithSystemdDBus(address string) Option {
+	return func(thisRef *options) {
+		thisRef.systemdDBus = true
+		thisRef.dbusAddress = address
+	}
+}
+
+// WithSocket - installs a `<name>.socket` unit next to the service so it starts on the first connection,
+// Start, Stop, Enable and Disable then act on the socket. Only systemd can do this.
+func WithSocket(socket Socket) Option {
+	return func(thisRef *options) {
+		thisRef.socket = &socket
+	}
+}
+
+// WithSchedule - runs the service on a schedule, with a `.timer` unit on systemd and an `/etc/cron.d` entry on SysV
+// and Upstart. Start, Stop, Enable and Disable then act on the timer or the cron entry.
+func WithSchedule(schedule Schedule) Option {
+	return func(thisRef *options) {
+		thisRef.schedule = &schedule
+	}
+}
+
+// WithNotify - the service tells systemd when it is ready, with `Type=notify`, see the `notify` package.
+// A `watchdog` above zero also sets `WatchdogSec=`, systemd restarts the service if it does not hear from it that often.
+// Only systemd can do this.
+func WithNotify(watchdog time.Duration) Option {
+	return func(thisRef *options) {
+		thisRef.notify = true
+		thisRef.watchdog = watchdog
+	}
+}
+
+// WithVerify - checks the units, ex: the service and its socket or timer, with `systemd-analyze verify` before installing them and refuses to install any
+// if systemd finds anything wrong, the problems come back as a `*ValidationError`.
+// On by default for `NewServiceFromPlatformTemplate()`, only systemd can do this.
+func WithVerify(verify bool) Option {
+	return func(thisRef *options) {
+		thisRef.verify = verify
+	}
+}
+
+// WithLinger - user services keep running after the user logs out and start at boot without a login,
+// Install runs `loginctl enable-linger`, Uninstall leaves it on as other services of the user may need it.
+// Only systemd can do this, for `ScopeUser`.
+func WithLinger() Option {
+	return func(thisRef *options) {
+		thisRef.linger = true
+	}
+}
+
+// WithLimits - caps the memory, CPU, tasks and open files of the service, systemd honors all of them,
+// SysV and Upstart all but `CPUQuota`. Limits a backend can't apply are logged as warnings and left out.
+func WithLimits(limits Limits) Option {
+	return func(thisRef *options) {
+		thisRef.limits = &limits
+	}
+}
+
+// WithRoot - installs into the filesystem mounted at `root` instead of `/`, ex: an OS image or a chroot.
+// Install and Uninstall only touch files, nothing is asked from the running init system.
+// Enable and Disable work offline where the init system keeps this state in files,
+// Start and Stop only do that with `WithEnableOnStart()`, anything else is `ErrServiceUnsupportedRequest`.
+func WithRoot(root string) Option {
+	return func(thisRef *options) {
+		thisRef.root = root
+	}
+}
+
+// isOffline - the service lives in another filesystem, the running init system can't be asked about it
+func (thisRef options) isOffline() bool {
+	return len(thisRef.root) > 0 && filepath.Clean(thisRef.root) != "/"
+}
+
+// rooted - `path` as seen from the host
+func (thisRef options) rooted(path string) string {
+	if !thisRef.isOffline() {
+		return path
+	}
+
+	return filepath.Join(thisRef.root, path)
+}
+
+// isUserScope - `ScopeAuto` is decided here, for init systems that have per-user services
+func (thisRef options) isUserScope() bool {
+	switch thisRef.scope {
+	case ScopeUser:
+		return true
+	case ScopeSystem:
+		return false
+	default:
+	}
+
+	return !helpers.IsRoot()
+}
+
+// forInitType - `ScopeAuto` is `ScopeSystem` where there are no per-user services, ex: SysV, or cron
+// running scheduled services on Upstart, `ScopeUser` is left for `validateFor()` to refuse
+func (thisRef options) forInitType(initType spec.InitType) options {
+	if thisRef.scope != ScopeAuto {
+		return thisRef
+	}
+
+	hasUserScope := initType == spec.InitSystemd || initType == InitLaunchd || (initType == spec.InitUpstart && thisRef.schedule == nil)
+	if !hasUserScope {
+		thisRef.scope = ScopeSystem
+	}
+
+	return thisRef
+}
+
+// errUserScope - what init systems without per-user services return for `ScopeUser`
+func (thisRef options) errUserScope(initSystem string) error {
+	return fmt.Errorf("%w: %s has no per-user services", ErrServiceUnsupportedRequest, initSystem)
+}
+
+// validateFor - options the init system can't honor are `ErrServiceUnsupportedRequest`
+func (thisRef options) validateFor(initType spec.InitType) error {
+	if thisRef.scope == ScopeUser && (initType == spec.InitSystemV || initType == spec.InitRC_D || initType == InitSCM) {
+		return thisRef.errUserScope(string(initType))
+	}
+
+	if thisRef.socket != nil && initType != spec.InitSystemd {
+		return fmt.Errorf("%w: socket activation needs systemd, not %s", ErrServiceUnsupportedRequest, initType)
+	}
+
+	if thisRef.notify && initType != spec.InitSystemd {
+		return fmt.Errorf("%w: readiness notification needs systemd, not %s", ErrServiceUnsupportedRequest, initType)
+	}
+
+	if thisRef.linger && (initType != spec.InitSystemd || !thisRef.isUserScope()) {
+		return fmt.Errorf("%w: lingering is for systemd user services", ErrServiceUnsupportedRequest)
+	}
+
+	if thisRef.schedule != nil {
+		if err := thisRef.schedule.validate(); err != nil {
+			return err
+		}
+
+		if thisRef.socket != nil {
+			return fmt.Errorf("%w: a service can't be both scheduled and socket-activated", ErrServiceUnsupportedRequest)
+		}
+
+		switch initType {
+		case spec.InitSystemd:
+		case spec.InitSystemV, spec.InitUpstart:
+			if thisRef.isUserScope() {
+				return fmt.Errorf("%w: /etc/cron.d has no per-user entries", ErrServiceUnsupportedRequest)
+			}
+			if _, err := thisRef.schedule.cron(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: scheduled services need systemd or cron, not %s", ErrServiceUnsupportedRequest, initType)
+		}
+	}
+
+	if thisRef.limits != nil {
+		if err := thisRef.limits.validate(); err != nil {
+			return err
+		}
+
+		// INFO: SysV and Upstart hand scheduled services to cron
+		backend := string(initType)
+		if thisRef.schedule != nil && (initType == spec.InitSystemV || initType == spec.InitUpstart) {
+			backend = "cron"
+		}
+
+		thisRef.limits.warnUnhonored(backend)
+	}
+
+	return nil
+}
+
+// errOffline - what operations that need the running init system return under `WithRoot()`
+func (thisRef options) errOffline(operation string) error {
+	return fmt.Errorf("%w: can't %s a service installed under %s", ErrServiceUnsupportedRequest, operation, thisRef.root)
+}
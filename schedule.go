@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Schedule - when a scheduled service runs, set one of the fields
+type Schedule struct {
+	Every   time.Duration `json:"every,omitempty"`   // ex: 15 * time.Minute, whole minutes
+	DailyAt string        `json:"dailyAt,omitempty"` // ex: 03:00, local time
+}
+
+func (thisRef Schedule) validate() error {
+	if (thisRef.Every > 0) == (len(thisRef.DailyAt) > 0) {
+		return fmt.Errorf("%w: a schedule needs exactly one of Every and DailyAt", ErrServiceConfigError)
+	}
+
+	if thisRef.Every > 0 && (thisRef.Every < time.Minute || thisRef.Every%time.Minute != 0) {
+		return fmt.Errorf("%w: schedule every %s, only whole minutes are supported", ErrServiceConfigError, thisRef.Every)
+	}
+
+	if len(thisRef.DailyAt) > 0 {
+		if _, _, err := thisRef.dailyAt(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dailyAt - hour and minute of `DailyAt`
+func (thisRef Schedule) dailyAt() (int, int, error) {
+	at, err := time.Parse("15:04", thisRef.DailyAt)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: schedule daily at %q, expected HH:MM", ErrServiceConfigError, thisRef.DailyAt)
+	}
+
+	return at.Hour(), at.Minute(), nil
+}
+
+// onCalendar - as systemd's `OnCalendar=`, empty if only a monotonic timer can express it
+func (thisRef Schedule) onCalendar() string {
+	if len(thisRef.DailyAt) > 0 {
+		hour, minute, _ := thisRef.dailyAt()
+		return fmt.Sprintf("*-*-* %02d:%02d:00", hour, minute)
+	}
+
+	minutes := int(thisRef.Every / time.Minute)
+	switch {
+	case minutes < 60 && 60%minutes == 0:
+		return "*:0/" + strconv.Itoa(minutes)
+	case minutes == 24*60:
+		return "daily"
+	case minutes%60 == 0 && minutes/60 < 24 && 24%(minutes/60) == 0:
+		return fmt.Sprintf("0/%d:00", minutes/60)
+	}
+
+	return ""
+}
+
+// cron - the five time fields of a crontab line, cron can only repeat on divisors of an hour or a day
+func (thisRef Schedule) cron() (string, error) {
+	if len(thisRef.DailyAt) > 0 {
+		hour, minute, _ := thisRef.dailyAt()
+		return fmt.Sprintf("%d %d * * *", minute, hour), nil
+	}
+
+	minutes := int(thisRef.Every / time.Minute)
+	switch {
+	case minutes == 1:
+		return "* * * * *", nil
+	case minutes < 60 && 60%minutes == 0:
+		return fmt.Sprintf("*/%d * * * *", minutes), nil
+	case minutes == 24*60:
+		return "0 0 * * *", nil
+	case minutes%60 == 0 && minutes/60 < 24 && 24%(minutes/60) == 0:
+		return fmt.Sprintf("0 */%d * * *", minutes/60), nil
+	}
+
+	return "", fmt.Errorf("%w: cron can't run something every %s", ErrServiceUnsupportedRequest, thisRef.Every)
+}
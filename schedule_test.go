@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		schedule   Schedule
+		onCalendar string
+		cron       string
+		err        error
+	}{
+		{schedule: Schedule{DailyAt: "03:30"}, onCalendar: "*-*-* 03:30:00", cron: "30 3 * * *"},
+		{schedule: Schedule{Every: time.Minute}, onCalendar: "*:0/1", cron: "* * * * *"},
+		{schedule: Schedule{Every: 15 * time.Minute}, onCalendar: "*:0/15", cron: "*/15 * * * *"},
+		{schedule: Schedule{Every: 6 * time.Hour}, onCalendar: "0/6:00", cron: "0 */6 * * *"},
+		{schedule: Schedule{Every: 24 * time.Hour}, onCalendar: "daily", cron: "0 0 * * *"},
+		{schedule: Schedule{Every: 7 * time.Minute}, onCalendar: "", err: ErrServiceUnsupportedRequest},
+		{schedule: Schedule{Every: 90 * time.Second}, err: ErrServiceConfigError},
+		{schedule: Schedule{DailyAt: "25:00"}, err: ErrServiceConfigError},
+		{schedule: Schedule{}, err: ErrServiceConfigError},
+		{schedule: Schedule{Every: time.Hour, DailyAt: "03:00"}, err: ErrServiceConfigError},
+	}
+
+	for _, test := range tests {
+		err := test.schedule.validate()
+		if test.err == ErrServiceConfigError {
+			if !errors.Is(err, ErrServiceConfigError) {
+				t.Errorf("%+v: expected a config error, got %v", test.schedule, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", test.schedule, err)
+			continue
+		}
+
+		if got := test.schedule.onCalendar(); got != test.onCalendar {
+			t.Errorf("%+v: expected OnCalendar %q, got %q", test.schedule, test.onCalendar, got)
+		}
+
+		cron, err := test.schedule.cron()
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%+v: expected %v, got %v", test.schedule, test.err, err)
+			}
+			continue
+		}
+		if err != nil || cron != test.cron {
+			t.Errorf("%+v: expected cron %q, got %q, %v", test.schedule, test.cron, cron, err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"time"
+
 	spec "github.com/codemodify/systemkit-service-spec"
 )
 
@@ -8,39 +11,131 @@ import (
 type Installer interface {
 	Install() error
 	Uninstall() error
+
+	InstallContext(ctx context.Context) error
+	UninstallContext(ctx context.Context) error
 }
 
-// Controller - starts and stops a service
+// Controller - starts, stops, restarts and reloads a service
 type Controller interface {
 	Start() error
 	Stop() error
+	Restart() error
+	Reload() error
+
+	StartContext(ctx context.Context) error
+	StopContext(ctx context.Context) error
+	RestartContext(ctx context.Context) error
+	ReloadContext(ctx context.Context) error
+}
+
+// Enabler - enables and disables a service at boot, independent of its runtime state
+type Enabler interface {
+	Enable() error
+	Disable() error
+	IsEnabled() (bool, error)
+
+	EnableContext(ctx context.Context) error
+	DisableContext(ctx context.Context) error
+	IsEnabledContext(ctx context.Context) (bool, error)
 }
 
 // Describer - gets info about a service
 type Describer interface {
 	Info() Info
+
+	InfoContext(ctx context.Context) Info
 }
 
 // Service -
 type Service interface {
 	Installer
 	Controller
+	Enabler
 	Describer
 }
 
+// DropInManager - layers named overrides on top of a unit file without rewriting it, ex: systemd's `<name>.service.d/*.conf`.
+// Only some services implement it, check with a type assertion.
+type DropInManager interface {
+	AddDropIn(name string, content string) error
+	RemoveDropIn(name string) error
+	ListDropIns() ([]DropIn, error)
+
+	AddDropInContext(ctx context.Context, name string, content string) error
+	RemoveDropInContext(ctx context.Context, name string) error
+	ListDropInsContext(ctx context.Context) ([]DropIn, error)
+}
+
+// Templater - a service installed once as a template and run as named instances, ex: `worker@` and `worker@tenantA`.
+// Template names end in `@`, `%i` and `%I` in the description are replaced with the instance name.
+// Only some services implement it, check with a type assertion.
+type Templater interface {
+	Instance(instance string) (Service, error)
+}
+
+// Masker - makes sure a service can't be started, not by hand and not as a dependency of another one, until it is unmasked.
+// Masking does not stop a running service and unmasking does not enable it again.
+// Only some services implement it, check with a type assertion.
+type Masker interface {
+	Mask() error
+	Unmask() error
+
+	MaskContext(ctx context.Context) error
+	UnmaskContext(ctx context.Context) error
+}
+
+// LogReader - reads what the service printed, from the journal or from the files in `SERVICE.Logging`.
+// FollowLogs streams the lines printed from now on, like `tail -f`, until `ctx` is done and then closes the channel,
+// if it has to stop before that, the last line it sends has the `Error`.
+// Only some services implement it, check with a type assertion.
+type LogReader interface {
+	Logs(since time.Time, limit int) ([]LogLine, error)
+	FollowLogs(ctx context.Context) (<-chan LogLine, error)
+
+	LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error)
+}
+
+// LogLine - a line the service printed
+type LogLine struct {
+	Time   *time.Time `json:"time,omitempty"` // nil if the line has no timestamp and none came before it
+	Source string     `json:"source"`         // ex: journal, /var/log/nginx.log
+	Text   string     `json:"text"`
+	Error  error      `json:"-"` // set on the last line FollowLogs sends if the stream ended on its own, ex: `journalctl` failed
+}
+
+// DropIn - an override file managed through DropInManager
+type DropIn struct {
+	Name     string `json:"name"` // ex: 10-environment.conf
+	FilePath string `json:"filePath"`
+	Content  string `json:"content"`
+}
+
 // NewServiceFromSERVICE -
-func NewServiceFromSERVICE(serviceSpec spec.SERVICE) Service {
-	return newServiceFromSERVICE(serviceSpec)
+func NewServiceFromSERVICE(serviceSpec spec.SERVICE, opts ...Option) (Service, error) {
+	return newServiceFromSERVICE(serviceSpec, newOptions(opts))
 }
 
 // NewServiceFromName -
-func NewServiceFromName(name string) (Service, error) {
-	return newServiceFromName(name)
+func NewServiceFromName(name string, opts ...Option) (Service, error) {
+	return newServiceFromName(name, newOptions(opts))
 }
 
 // NewServiceFromPlatformTemplate -
-func NewServiceFromPlatformTemplate(name string, template string) (Service, error) {
-	return newServiceFromPlatformTemplate(name, template)
+func NewServiceFromPlatformTemplate(name string, template string, opts ...Option) (Service, error) {
+	// INFO: a hand written unit is where typos happen, `WithVerify(false)` in `opts` still wins
+	return newServiceFromPlatformTemplate(name, template, newOptions(append([]Option{WithVerify(true)}, opts...)))
+}
+
+// RunTransient - runs `serviceSpec` once without installing it, ex: a migration, systemd only.
+// Nothing is written to disk and the init system forgets the service after it exits, its logs stay.
+func RunTransient(serviceSpec spec.SERVICE, opts ...Option) (Service, error) {
+	return RunTransientContext(context.Background(), serviceSpec, opts...)
+}
+
+// RunTransientContext -
+func RunTransientContext(ctx context.Context, serviceSpec spec.SERVICE, opts ...Option) (Service, error) {
+	return runTransient(ctx, serviceSpec, newOptions(opts))
 }
 
 // Info -
@@ -48,7 +143,35 @@ type Info struct {
 	Error       error        `json:"-"`
 	Service     spec.SERVICE `json:"config,omitempty"`
 	IsRunning   bool         `json:"isRunning"`
+	IsMasked    bool         `json:"isMasked"`
 	PID         int          `json:"pid,omitempty"`
 	FilePath    string       `json:"filePath,omitempty"`
 	FileContent string       `json:"fileContent,omitempty"`
+	DropIns     []string     `json:"dropIns,omitempty"` // override files the init system applied on top of `FilePath`
+	Status      Status       `json:"status"`
+}
+
+// State - runtime state of a service, modeled after systemd's `ActiveState=`
+type State string
+
+const (
+	StateActive       = State("active")
+	StateInactive     = State("inactive")
+	StateActivating   = State("activating")
+	StateDeactivating = State("deactivating")
+	StateFailed       = State("failed")
+	StateUnknown      = State("unknown")
+)
+
+// Status - what the init system knows about the service, fields a backend can't tell are left empty
+type Status struct {
+	State        State      `json:"state"`                  // active, inactive, activating, deactivating, failed, unknown
+	SubState     string     `json:"subState,omitempty"`     // init system specific, ex: running, exited, waiting
+	ExitCode     int        `json:"exitCode"`               // exit code of the last run of the main process
+	ExitSignal   int        `json:"exitSignal,omitempty"`   // signal that terminated the last run of the main process
+	Since        *time.Time `json:"since,omitempty"`        // when the service entered `State`
+	RestartCount int        `json:"restartCount"`           // how many times the init system restarted the service
+	EnabledState string     `json:"enabledState,omitempty"` // ex: enabled, disabled, static, masked
+	LastTrigger  *time.Time `json:"lastTrigger,omitempty"`  // scheduled services, when the schedule last started it
+	NextTrigger  *time.Time `json:"nextTrigger,omitempty"`  // scheduled services, when the schedule starts it next
 }
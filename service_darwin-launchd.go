@@ -3,11 +3,14 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	logging "github.com/codemodify/systemkit-logging"
 	encoders "github.com/codemodify/systemkit-service-encoders-launchd"
@@ -21,13 +24,18 @@ type launchdService struct {
 	serviceSpec            spec.SERVICE
 	useConfigAsFileContent bool
 	fileContentTemplate    string
+	opts                   options
 }
 
-func newServiceFromSERVICE(serviceSpec spec.SERVICE) Service {
+func newServiceFromSERVICE(serviceSpec spec.SERVICE, opts options) (Service, error) {
+	if err := opts.validateFor(InitLaunchd); err != nil {
+		return nil, err
+	}
+
 	// override some values - platform specific
 	// https://developer.apple.com/library/archive/documentation/MacOSX/Conceptual/BPSystemStartup/Chapters/CreatingLaunchdJobs.html
 	logDir := filepath.Join(helpers.HomeDir(""), "Library/Logs", serviceSpec.Name)
-	if helpers.IsRoot() {
+	if !opts.isUserScope() {
 		logDir = filepath.Join("/Library/Logs", serviceSpec.Name)
 	}
 
@@ -44,15 +52,24 @@ func newServiceFromSERVICE(serviceSpec spec.SERVICE) Service {
 	launchdService := &launchdService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: true,
+		opts:                   opts,
 	}
 
-	return launchdService
+	return launchdService, nil
+}
+
+func detectInitSystem(ctx context.Context, root string, executor Executor) InitSystemReport {
+	return InitSystemReport{Type: InitLaunchd, Evidence: []string{"launchd is the only init system on darwin"}}
 }
 
-func newServiceFromName(name string) (Service, error) {
-	serviceFile := filepath.Join(helpers.HomeDir(""), "Library/LaunchAgents", name+".plist")
-	if helpers.IsRoot() {
-		serviceFile = filepath.Join("/Library/LaunchDaemons", name+".plist")
+func newServiceFromName(name string, opts options) (Service, error) {
+	if err := opts.validateFor(InitLaunchd); err != nil {
+		return nil, err
+	}
+
+	serviceFile := opts.rooted(filepath.Join(helpers.HomeDir(""), "Library/LaunchAgents", name+".plist"))
+	if !opts.isUserScope() {
+		serviceFile = opts.rooted(filepath.Join("/Library/LaunchDaemons", name+".plist"))
 	}
 
 	fileContent, err := ioutil.ReadFile(serviceFile)
@@ -60,20 +77,38 @@ func newServiceFromName(name string) (Service, error) {
 		return nil, ErrServiceDoesNotExist
 	}
 
-	return newServiceFromPlatformTemplate(name, string(fileContent))
+	return newServiceFromPlatformTemplate(name, string(fileContent), opts)
 }
 
-func newServiceFromPlatformTemplate(name string, template string) (Service, error) {
+func newServiceFromPlatformTemplate(name string, template string, opts options) (Service, error) {
+	if err := opts.validateFor(InitLaunchd); err != nil {
+		return nil, err
+	}
+
 	logging.Debugf("%s: template: %s", logTag, template)
 
 	return &launchdService{
 		serviceSpec:            encoders.LaunchDToSERVICE(template),
 		useConfigAsFileContent: false,
 		fileContentTemplate:    template,
+		opts:                   opts,
 	}, nil
 }
 
+// runTransient - transient services are a systemd feature
+func runTransient(ctx context.Context, serviceSpec spec.SERVICE, opts options) (Service, error) {
+	return nil, ErrServiceUnsupportedRequest
+}
+
 func (thisRef launchdService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef launchdService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
 	dir := filepath.Dir(thisRef.filePath())
 
 	// 1.
@@ -96,15 +131,21 @@ func (thisRef launchdService) Install() error {
 }
 
 func (thisRef launchdService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef launchdService) UninstallContext(ctx context.Context) error {
 	// 1.
-	err := thisRef.Stop()
-	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
-		return err
+	if !thisRef.opts.isOffline() {
+		err := thisRef.StopContext(ctx)
+		if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+			return err
+		}
 	}
 
 	// 2.
 	logging.Debugf("%s: remove plist file: %s", logTag, thisRef.filePath())
-	err = os.Remove(thisRef.filePath())
+	err := os.Remove(thisRef.filePath())
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "no such file or directory") {
 			return nil
@@ -116,13 +157,21 @@ func (thisRef launchdService) Uninstall() error {
 	// INFO: ignore the return value as is it is barely defined by the docs
 	// what the expected behavior would be. The previous stop and remove the "plist" file
 	// will uninstall the service.
-	runLaunchCtlCommand("remove", thisRef.serviceSpec.Name)
+	thisRef.runLaunchCtlCommand(ctx, "remove", thisRef.serviceSpec.Name)
 	return nil
 }
 
 func (thisRef launchdService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef launchdService) StartContext(ctx context.Context) error {
 	// 1.
-	output, _ := runLaunchCtlCommand("load", "-w", thisRef.filePath())
+	output, _ := thisRef.runLaunchCtlCommand(ctx, "load", "-w", thisRef.filePath())
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
 	if strings.Contains(output, "No such file or directory") {
 		return ErrServiceDoesNotExist
 	} else if strings.Contains(output, "Invalid property list") {
@@ -135,21 +184,94 @@ func (thisRef launchdService) Start() error {
 		return nil
 	}
 
-	runLaunchCtlCommand("start", thisRef.serviceSpec.Name)
+	thisRef.runLaunchCtlCommand(ctx, "start", thisRef.serviceSpec.Name)
 	return nil
 }
 
 func (thisRef launchdService) Stop() error {
-	runLaunchCtlCommand("stop", thisRef.serviceSpec.Name)
-	output, err := runLaunchCtlCommand("unload", thisRef.filePath())
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef launchdService) StopContext(ctx context.Context) error {
+	thisRef.runLaunchCtlCommand(ctx, "stop", thisRef.serviceSpec.Name)
+	output, err := thisRef.runLaunchCtlCommand(ctx, "unload", thisRef.filePath())
 	if strings.Contains(output, "Could not find specified service") {
-		return ErrServiceDoesNotExist
+		return reclassify(err, ErrServiceDoesNotExist)
 	}
 
 	return err
 }
 
+func (thisRef launchdService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef launchdService) RestartContext(ctx context.Context) error {
+	err := thisRef.StopContext(ctx)
+	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+		return err
+	}
+
+	return thisRef.StartContext(ctx)
+}
+
+func (thisRef launchdService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+// ReloadContext - launchd has no notion of reloading a job
+func (thisRef launchdService) ReloadContext(ctx context.Context) error {
+	return ErrServiceUnsupportedRequest
+}
+
+func (thisRef launchdService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+func (thisRef launchdService) EnableContext(ctx context.Context) error {
+	_, err := thisRef.runLaunchCtlCommand(ctx, "enable", thisRef.serviceTarget())
+	return err
+}
+
+func (thisRef launchdService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+func (thisRef launchdService) DisableContext(ctx context.Context) error {
+	_, err := thisRef.runLaunchCtlCommand(ctx, "disable", thisRef.serviceTarget())
+	return err
+}
+
+func (thisRef launchdService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef launchdService) IsEnabledContext(ctx context.Context) (bool, error) {
+	if _, err := os.Stat(thisRef.filePath()); os.IsNotExist(err) {
+		return false, ErrServiceDoesNotExist
+	}
+
+	output, err := thisRef.runLaunchCtlCommand(ctx, "print-disabled", thisRef.domainTarget())
+	if err != nil {
+		return false, err
+	}
+
+	// lines look like `"label" => true` on older systems and `"label" => disabled` on newer ones,
+	// a label that is not listed is enabled
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "\""+thisRef.serviceSpec.Name+"\"") {
+			return !strings.Contains(line, "=> true") && !strings.Contains(line, "=> disabled"), nil
+		}
+	}
+
+	return true, nil
+}
+
 func (thisRef launchdService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+func (thisRef launchdService) InfoContext(ctx context.Context) Info {
 	fileContent, fileContentErr := ioutil.ReadFile(thisRef.filePath())
 
 	result := Info{
@@ -159,29 +281,45 @@ func (thisRef launchdService) Info() Info {
 		PID:         -1,
 		FilePath:    thisRef.filePath(),
 		FileContent: string(fileContent),
+		Status:      Status{State: StateUnknown},
 	}
 
 	if fileContentErr != nil || len(fileContent) <= 0 {
 		result.Error = ErrServiceDoesNotExist
 	}
 
-	output, err := runLaunchCtlCommand("list")
+	if thisRef.opts.isOffline() {
+		return result
+	}
+
+	output, err := thisRef.runLaunchCtlCommand(ctx, "list")
 	if err != nil {
 		result.Error = err
 		logging.Errorf("error getting launchctl status: %s", err)
 		return result
 	}
 
+	isListed := false
 	lines := strings.Split(strings.TrimSpace(output), "\n")
 	for _, line := range lines {
 		chunks := strings.Split(line, "\t")
 
-		if chunks[2] == thisRef.serviceSpec.Name {
+		if len(chunks) >= 3 && chunks[2] == thisRef.serviceSpec.Name {
+			isListed = true
+
 			if chunks[0] != "-" {
 				pid, _ := strconv.Atoi(chunks[0])
 				result.PID = pid
 			}
 
+			// second column is the last exit status, negative for a signal
+			lastExitStatus, _ := strconv.Atoi(chunks[1])
+			if lastExitStatus < 0 {
+				result.Status.ExitSignal = -lastExitStatus
+			} else {
+				result.Status.ExitCode = lastExitStatus
+			}
+
 			if result.PID != -1 {
 				result.IsRunning = true
 			}
@@ -190,25 +328,99 @@ func (thisRef launchdService) Info() Info {
 		}
 	}
 
+	// INFO: neither loaded nor installed, there is nothing to say about its state
+	if !isListed && result.Error != nil {
+		return result
+	}
+
+	result.Status.State = StateInactive
+	if result.IsRunning {
+		result.Status.State = StateActive
+	}
+
 	return result
 }
 
+func (thisRef launchdService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.LogsContext(context.Background(), since, limit)
+}
+
+func (thisRef launchdService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return readLogFiles(paths, since, limit)
+}
+
+func (thisRef launchdService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return followLogFiles(ctx, paths), nil
+}
+
+// logFilePaths - `StandardOutPath` and `StandardErrorPath`, the ones `SERVICE.Logging` asked for
+func (thisRef launchdService) logFilePaths() ([]string, error) {
+	paths := logFilePaths(thisRef.serviceSpec.Logging, "", "")
+	if len(paths) <= 0 {
+		return nil, fmt.Errorf("%w: %s does not log to a file", ErrServiceUnsupportedRequest, thisRef.serviceSpec.Name)
+	}
+
+	for i := range paths {
+		paths[i] = thisRef.opts.rooted(paths[i])
+	}
+
+	return paths, nil
+}
+
 func (thisRef launchdService) filePath() string {
-	if helpers.IsRoot() {
-		return filepath.Join("/Library/LaunchDaemons", thisRef.serviceSpec.Name+".plist")
+	if !thisRef.opts.isUserScope() {
+		return thisRef.opts.rooted(filepath.Join("/Library/LaunchDaemons", thisRef.serviceSpec.Name+".plist"))
 	}
 
-	return filepath.Join(helpers.HomeDir(""), "Library/LaunchAgents", thisRef.serviceSpec.Name+".plist")
+	return thisRef.opts.rooted(filepath.Join(helpers.HomeDir(""), "Library/LaunchAgents", thisRef.serviceSpec.Name+".plist"))
 }
 
-func runLaunchCtlCommand(args ...string) (string, error) {
+func (thisRef launchdService) domainTarget() string {
+	if !thisRef.opts.isUserScope() {
+		return "system"
+	}
+
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+func (thisRef launchdService) serviceTarget() string {
+	return thisRef.domainTarget() + "/" + thisRef.serviceSpec.Name
+}
+
+func (thisRef launchdService) runLaunchCtlCommand(ctx context.Context, args ...string) (string, error) {
 	// if !helpers.IsRoot() {
 	// 	args = append([]string{"--user"}, args...)
 	// }
 
+	// INFO: the enabled/disabled state lives in launchd's own database, nothing can be done offline
+	if thisRef.opts.isOffline() {
+		return "", thisRef.opts.errOffline(operationFromArgs(args))
+	}
+
 	logging.Debugf("%s: RUN-LAUNCHCTL: launchctl %s", logTag, strings.Join(args, " "))
 
-	output, err := helpers.ExecWithArgs("launchctl", args...)
+	output, err := thisRef.opts.executor.Exec(ctx, "launchctl", args...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	errAsString := ""
 	if err != nil {
 		errAsString = err.Error()
@@ -216,5 +428,5 @@ func runLaunchCtlCommand(args ...string) (string, error) {
 
 	logging.Debugf("%s: RUN-LAUNCHCTL-OUT: output: %s, error: %s", logTag, output, errAsString)
 
-	return output, err
+	return output, newOperationError("launchd", operationFromArgs(args), append([]string{"launchctl"}, args...), output, err)
 }
@@ -3,9 +3,11 @@
 package service
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	logging "github.com/codemodify/systemkit-logging"
@@ -20,32 +22,50 @@ type rcdService struct {
 	serviceSpec            spec.SERVICE
 	useConfigAsFileContent bool
 	fileContentTemplate    string
+	opts                   options
 }
 
-func newServiceFromSERVICE(serviceSpec spec.SERVICE) Service {
+func newServiceFromSERVICE(serviceSpec spec.SERVICE, opts options) (Service, error) {
+	if err := opts.validateFor(spec.InitRC_D); err != nil {
+		return nil, err
+	}
+
 	logging.Debugf("%s: serviceSpec object: %s", logTagRCD, helpers.AsJSONString(serviceSpec))
 
 	return &rcdService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: true,
-	}
+		opts:                   opts,
+	}, nil
 }
 
-func newServiceFromName(name string) (Service, error) {
-	serviceFile := filepath.Join("/etc/rc.d/", name)
+func detectInitSystem(ctx context.Context, root string, executor Executor) InitSystemReport {
+	return InitSystemReport{Type: spec.InitRC_D, Evidence: []string{"rc.d is the only init system on freebsd"}}
+}
+
+func newServiceFromName(name string, opts options) (Service, error) {
+	if err := opts.validateFor(spec.InitRC_D); err != nil {
+		return nil, err
+	}
+
+	serviceFile := opts.rooted(filepath.Join("/etc/rc.d/", name))
 	fileContent, err := ioutil.ReadFile(serviceFile)
 	if err != nil {
-		serviceFile = filepath.Join("/usr/local/etc/rc.d/", name)
+		serviceFile = opts.rooted(filepath.Join("/usr/local/etc/rc.d/", name))
 		fileContent, err = ioutil.ReadFile(serviceFile)
 		if err != nil {
 			return nil, ErrServiceDoesNotExist
 		}
 	}
 
-	return newServiceFromPlatformTemplate(name, string(fileContent))
+	return newServiceFromPlatformTemplate(name, string(fileContent), opts)
 }
 
-func newServiceFromPlatformTemplate(name string, template string) (Service, error) {
+func newServiceFromPlatformTemplate(name string, template string, opts options) (Service, error) {
+	if err := opts.validateFor(spec.InitRC_D); err != nil {
+		return nil, err
+	}
+
 	logging.Debugf("%s: template: %s", logTagRCD, template)
 
 	serviceSpec := encoders.RC_DToSERVICE(template)
@@ -54,10 +74,24 @@ func newServiceFromPlatformTemplate(name string, template string) (Service, erro
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: false,
 		fileContentTemplate:    template,
+		opts:                   opts,
 	}, nil
 }
 
+// runTransient - transient services are a systemd feature
+func runTransient(ctx context.Context, serviceSpec spec.SERVICE, opts options) (Service, error) {
+	return nil, ErrServiceUnsupportedRequest
+}
+
 func (thisRef rcdService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef rcdService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
 	dir := filepath.Dir(thisRef.filePath())
 
 	// 1.
@@ -98,18 +132,24 @@ func (thisRef rcdService) Install() error {
 }
 
 func (thisRef rcdService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef rcdService) UninstallContext(ctx context.Context) error {
 	// 1.
 	logging.Debugf("%s: attempting to uninstall: %s", logTagRCD, thisRef.serviceSpec.Name)
 
 	// 2.
-	err := thisRef.Stop()
-	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
-		return err
+	if !thisRef.opts.isOffline() {
+		err := thisRef.StopContext(ctx)
+		if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+			return err
+		}
 	}
 
 	// 3.
 	logging.Debugf("remove unit file")
-	err = os.Remove(thisRef.filePath())
+	err := os.Remove(thisRef.filePath())
 	if e, ok := err.(*os.PathError); ok {
 		if os.IsNotExist(e.Err) {
 			return nil
@@ -120,12 +160,16 @@ func (thisRef rcdService) Uninstall() error {
 }
 
 func (thisRef rcdService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef rcdService) StartContext(ctx context.Context) error {
 	// 1.
 	logging.Debugf("loading unit file with systemd")
-	output, err := runServiceCommand(thisRef.serviceSpec.Name, "start")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "start")
 	if err != nil {
 		if strings.Contains(output, "Failed to start") && strings.Contains(output, "not found") {
-			return ErrServiceDoesNotExist
+			return reclassify(err, ErrServiceDoesNotExist)
 		}
 
 		return err
@@ -135,12 +179,35 @@ func (thisRef rcdService) Start() error {
 }
 
 func (thisRef rcdService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef rcdService) StopContext(ctx context.Context) error {
 	// 1.
 	logging.Debugf("stopping service")
-	output, err := runServiceCommand(thisRef.serviceSpec.Name, "stop")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "stop")
 	if err != nil {
 		if strings.Contains(output, "Failed to stop") && strings.Contains(output, "not loaded") {
-			return ErrServiceDoesNotExist
+			return reclassify(err, ErrServiceDoesNotExist)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef rcdService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef rcdService) RestartContext(ctx context.Context) error {
+	// 1.
+	logging.Debugf("restarting service")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "restart")
+	if err != nil {
+		if strings.Contains(output, "does not exist in") {
+			return reclassify(err, ErrServiceDoesNotExist)
 		}
 
 		return err
@@ -149,7 +216,86 @@ func (thisRef rcdService) Stop() error {
 	return nil
 }
 
+func (thisRef rcdService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef rcdService) ReloadContext(ctx context.Context) error {
+	// 1.
+	logging.Debugf("reloading service")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "reload")
+	if err != nil {
+		if strings.Contains(output, "does not exist in") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		} else if strings.Contains(output, "unknown directive") {
+			return reclassify(err, ErrServiceUnsupportedRequest)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef rcdService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+// EnableContext - sets `<name>_enable="YES"` in rc.conf
+func (thisRef rcdService) EnableContext(ctx context.Context) error {
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "enable")
+	if err != nil {
+		if strings.Contains(output, "does not exist in") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef rcdService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+// DisableContext - sets `<name>_enable="NO"` in rc.conf
+func (thisRef rcdService) DisableContext(ctx context.Context) error {
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "disable")
+	if err != nil {
+		if strings.Contains(output, "does not exist in") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef rcdService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef rcdService) IsEnabledContext(ctx context.Context) (bool, error) {
+	// INFO: `service <name> enabled` reports only through its exit code
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "enabled")
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if strings.Contains(output, "does not exist in") {
+		return false, ErrServiceDoesNotExist
+	}
+
+	return err == nil, nil
+}
+
 func (thisRef rcdService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+func (thisRef rcdService) InfoContext(ctx context.Context) Info {
 	fileContent, _ := ioutil.ReadFile(thisRef.filePath())
 
 	result := Info{
@@ -159,43 +305,66 @@ func (thisRef rcdService) Info() Info {
 		PID:         -1,
 		FilePath:    thisRef.filePath(),
 		FileContent: string(fileContent),
+		Status:      Status{State: StateUnknown},
 	}
 
-	// output, err := runServiceCommand("status", thisRef.serviceSpec.Name)
-	// if err != nil {
-	// 	result.Error = err
-	// 	return result
-	// }
+	if thisRef.opts.isOffline() {
+		if len(fileContent) <= 0 {
+			result.Error = ErrServiceDoesNotExist
+		}
 
-	// if strings.Contains(output, "could not be found") {
-	// 	result.Error = ErrServiceDoesNotExist
-	// 	return result
-	// }
+		return result
+	}
 
-	// for _, line := range strings.Split(output, "\n") {
-	// 	if strings.Contains(line, "Main PID") {
-	// 		lineParts := strings.Split(strings.TrimSpace(line), " ")
-	// 		if len(lineParts) >= 2 {
-	// 			result.PID, _ = strconv.Atoi(lineParts[2])
-	// 		}
-	// 	} else if strings.Contains(line, "Active") {
-	// 		if strings.Contains(line, "active (running)") {
-	// 			result.IsRunning = true
-	// 		}
-	// 	}
-	// }
+	// INFO: rc.d scripts report through the exit code of `status`
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "status")
+	if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		return result
+	}
+
+	if strings.Contains(output, "does not exist in") {
+		result.Error = ErrServiceDoesNotExist
+		return result
+	}
+
+	result.IsRunning = (err == nil)
+	result.Status.State = StateInactive
+	if result.IsRunning {
+		result.Status.State = StateActive
+	}
+
+	// ex: name is running as pid 1234.
+	if pidIndex := strings.Index(output, "as pid "); pidIndex != -1 {
+		result.PID, _ = strconv.Atoi(strings.TrimRight(strings.Fields(output[pidIndex+len("as pid "):])[0], "."))
+	}
+
+	if isEnabled, err := thisRef.IsEnabledContext(ctx); err == nil {
+		result.Status.EnabledState = "disabled"
+		if isEnabled {
+			result.Status.EnabledState = "enabled"
+		}
+	}
 
 	return result
 }
 
 func (thisRef rcdService) filePath() string {
-	return filepath.Join("/etc/rc.d", thisRef.serviceSpec.Name)
+	return thisRef.opts.rooted(filepath.Join("/etc/rc.d", thisRef.serviceSpec.Name))
 }
 
-func runServiceCommand(args ...string) (string, error) {
+func (thisRef rcdService) runServiceCommand(ctx context.Context, args ...string) (string, error) {
+	// INFO: `service` only talks to the running system, even `enable` goes through its rc.conf
+	if thisRef.opts.isOffline() {
+		return "", thisRef.opts.errOffline(args[len(args)-1])
+	}
+
 	logging.Debugf("%s: RUN-SERVICE: service %s", logTagRCD, strings.Join(args, " "))
 
-	output, err := helpers.ExecWithArgs("service", args...)
+	output, err := thisRef.opts.executor.Exec(ctx, "service", args...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	errAsString := ""
 	if err != nil {
 		errAsString = err.Error()
@@ -203,5 +372,5 @@ func runServiceCommand(args ...string) (string, error) {
 
 	logging.Debugf("%s: RUN-SERVICE-OUT: output: %s, error: %s", logTagRCD, output, errAsString)
 
-	return output, err
+	return output, newOperationError(string(spec.InitRC_D), args[len(args)-1], append([]string{"service"}, args...), output, err)
 }
@@ -0,0 +1,83 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestCancelKillsCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemkit-cancel")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sleeperSpec := spec.NewEmptySERVICE()
+	sleeperSpec.Name = "sleeper"
+	sleeperSpec.Executable = "/usr/bin/sleeper"
+
+	for _, testCase := range []struct {
+		name string
+		run  func(ctx context.Context, executor Executor) error
+	}{
+		{"systemctl", func(ctx context.Context, executor Executor) error {
+			return newServiceFromSERVICE_SystemD(sleeperSpec, newOptions([]Option{WithExecutor(executor)})).StopContext(ctx)
+		}},
+		{"systemd-run", func(ctx context.Context, executor Executor) error {
+			_, err := RunTransientContext(ctx, sleeperSpec, WithExecutor(executor), WithInitType(spec.InitSystemd), WithScope(ScopeSystem))
+			return err
+		}},
+		{"service", func(ctx context.Context, executor Executor) error {
+			return newServiceFromSERVICE_SystemV(sleeperSpec, newOptions([]Option{WithExecutor(executor)})).StartContext(ctx)
+		}},
+		{"initctl", func(ctx context.Context, executor Executor) error {
+			return newServiceFromSERVICE_Upstart(sleeperSpec, newOptions([]Option{WithExecutor(executor)})).StartContext(ctx)
+		}},
+	} {
+		// INFO: whatever the backend asks for, a shell that says who it is and sleeps runs instead
+		pidFile := filepath.Join(dir, testCase.name+".pid")
+		executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+			return NewDefaultExecutor().Exec(ctx, "sh", "-c", "echo $$ > "+pidFile+"; exec sleep 30")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- testCase.run(ctx, executor) }()
+
+		pid := 0
+		for deadline := time.Now().Add(5 * time.Second); pid <= 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			pidAsString, _ := ioutil.ReadFile(pidFile)
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(pidAsString)))
+		}
+		if pid <= 0 {
+			cancel()
+			t.Fatalf("%s: the command never ran", testCase.name)
+		}
+
+		cancel()
+
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("%s: expected context.Canceled, got %v", testCase.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: still waiting for the command after cancelling", testCase.name)
+		}
+
+		if err := syscall.Kill(pid, 0); err != syscall.ESRCH {
+			t.Errorf("%s: expected the command killed, got %v", testCase.name, err)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+	"github.com/codemodify/systemkit-service/helpers"
+)
+
+var logTagCron = "CRON-SERVICE"
+
+// cronDir - entries here name the user to run as, unlike crontabs
+const cronDir = "/etc/cron.d"
+
+// cronDisabledMarker - prefix of the job line of a disabled entry, cron ignores it as a comment
+const cronDisabledMarker = "#disabled# "
+
+// cronRedirectRegex - the `>> 'file'` and `2>> 'file'` that `fileContent()` adds to the job line for `SERVICE.Logging`
+var cronRedirectRegex = regexp.MustCompile(`(?:^|\s)(2?)>> '((?:[^']|'\\'')*)'`)
+
+// cronService - scheduled services on SysV and Upstart hosts, the init system has no timers so cron runs them.
+// The `/etc/cron.d` entry is armed or not with Enable and Disable, there is nothing running to Start, Stop or Restart.
+type cronService struct {
+	serviceSpec spec.SERVICE
+	opts        options
+}
+
+func newServiceFromSERVICE_Cron(serviceSpec spec.SERVICE, opts options) Service {
+	logging.Debugf("%s: serviceSpec object: %s", logTagCron, helpers.AsJSONString(serviceSpec))
+
+	return &cronService{
+		serviceSpec: serviceSpec,
+		opts:        opts,
+	}
+}
+
+func newServiceFromName_Cron(name string, opts options) (Service, error) {
+	probe := cronService{serviceSpec: spec.SERVICE{Name: name}, opts: opts}
+	fileContent, err := ioutil.ReadFile(probe.filePath())
+	if err != nil {
+		return nil, ErrServiceDoesNotExist
+	}
+
+	// INFO: where the job logs is only in its line, `LogReader` needs it
+	probe.serviceSpec.Logging = cronLoggingFromEntry(string(fileContent))
+
+	return &probe, nil
+}
+
+func (thisRef cronService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef cronService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 1.
+	fileContent, err := thisRef.fileContent()
+	if err != nil {
+		return err
+	}
+
+	// 2.
+	logging.Debugf("%s: writing cron entry to: %s", logTagCron, thisRef.filePath())
+	os.MkdirAll(filepath.Dir(thisRef.filePath()), os.ModePerm)
+
+	// INFO: cron ignores files that are writable by others
+	return ioutil.WriteFile(thisRef.filePath(), []byte(fileContent), 0644)
+}
+
+func (thisRef cronService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef cronService) UninstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	logging.Debugf("%s: remove cron entry: %s", logTagCron, thisRef.filePath())
+	err := os.Remove(thisRef.filePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (thisRef cronService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+// StartContext - cron runs the job on its schedule, not on demand
+func (thisRef cronService) StartContext(ctx context.Context) error {
+	return thisRef.errNotRunning("start")
+}
+
+func (thisRef cronService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+// StopContext - cron does not keep track of the jobs it started
+func (thisRef cronService) StopContext(ctx context.Context) error {
+	return thisRef.errNotRunning("stop")
+}
+
+func (thisRef cronService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+// RestartContext - cron re-reads `/etc/cron.d` on its own, there is nothing to restart
+func (thisRef cronService) RestartContext(ctx context.Context) error {
+	return thisRef.errNotRunning("restart")
+}
+
+func (thisRef cronService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef cronService) ReloadContext(ctx context.Context) error {
+	return thisRef.errNotRunning("reload")
+}
+
+func (thisRef cronService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+func (thisRef cronService) EnableContext(ctx context.Context) error {
+	return thisRef.setEnabled(ctx, true)
+}
+
+func (thisRef cronService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+func (thisRef cronService) DisableContext(ctx context.Context) error {
+	return thisRef.setEnabled(ctx, false)
+}
+
+func (thisRef cronService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef cronService) IsEnabledContext(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	fileContent, err := ioutil.ReadFile(thisRef.filePath())
+	if err != nil {
+		return false, ErrServiceDoesNotExist
+	}
+
+	return !strings.Contains(string(fileContent), cronDisabledMarker), nil
+}
+
+func (thisRef cronService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+// InfoContext - cron does not keep any state, the entry is active while it is enabled
+func (thisRef cronService) InfoContext(ctx context.Context) Info {
+	fileContent, _ := ioutil.ReadFile(thisRef.filePath())
+
+	result := Info{
+		Error:       nil,
+		Service:     thisRef.serviceSpec,
+		IsRunning:   false,
+		PID:         -1,
+		FilePath:    thisRef.filePath(),
+		FileContent: string(fileContent),
+		Status:      Status{State: StateUnknown},
+	}
+
+	if len(fileContent) <= 0 {
+		result.Error = ErrServiceDoesNotExist
+		return result
+	}
+
+	isEnabled, err := thisRef.IsEnabledContext(ctx)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	result.Status.EnabledState = enabledStateAsString(isEnabled)
+	result.Status.State = StateInactive
+	if isEnabled {
+		result.Status.State = StateActive
+	}
+
+	return result
+}
+
+func (thisRef cronService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.LogsContext(context.Background(), since, limit)
+}
+
+func (thisRef cronService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return readLogFiles(paths, since, limit)
+}
+
+func (thisRef cronService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return followLogFiles(ctx, paths), nil
+}
+
+// logFilePaths - from `SERVICE.Logging`, without a file cron mails the output to the owner of the entry
+func (thisRef cronService) logFilePaths() ([]string, error) {
+	paths := logFilePaths(thisRef.serviceSpec.Logging, "", "")
+	if len(paths) <= 0 {
+		return nil, fmt.Errorf("%w: %s does not log to a file", ErrServiceUnsupportedRequest, thisRef.serviceSpec.Name)
+	}
+
+	for i := range paths {
+		paths[i] = thisRef.opts.rooted(paths[i])
+	}
+
+	return paths, nil
+}
+
+func (thisRef cronService) errNotRunning(operation string) error {
+	return fmt.Errorf("%w: %s: %s runs on a schedule, use Enable and Disable", ErrServiceUnsupportedRequest, operation, thisRef.serviceSpec.Name)
+}
+
+// filePath - cron skips file names with dots in them
+func (thisRef cronService) filePath() string {
+	return thisRef.opts.rooted(filepath.Join(cronDir, cronFileName(thisRef.serviceSpec.Name)))
+}
+
+// cronFileName - cron skips entries with names other than letters, digits, `_` and `-`, ex: `worker@a.b` is `worker_a_b`
+func cronFileName(name string) string {
+	return strings.NewReplacer(".", "_", "@", "_").Replace(name)
+}
+
+// fileContent - the environment, then a single job line that runs the executable as the service's user
+func (thisRef cronService) fileContent() (string, error) {
+	timeFields, err := thisRef.opts.schedule.cron()
+	if err != nil {
+		return "", err
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("# %s\n", thisRef.serviceSpec.Name))
+	if len(thisRef.serviceSpec.Description) > 0 {
+		sb.WriteString(fmt.Sprintf("# %s\n", thisRef.serviceSpec.Description))
+	}
+
+	sb.WriteString("SHELL=/bin/sh\n")
+	if _, ok := thisRef.serviceSpec.Environment["PATH"]; !ok {
+		sb.WriteString("PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin\n")
+	}
+
+	environmentKeys := []string{}
+	for key := range thisRef.serviceSpec.Environment {
+		environmentKeys = append(environmentKeys, key)
+	}
+	sort.Strings(environmentKeys)
+
+	for _, key := range environmentKeys {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", key, thisRef.serviceSpec.Environment[key]))
+	}
+
+	user := thisRef.serviceSpec.Credentials.User
+	if len(user) <= 0 {
+		user = "root"
+	}
+
+	command := shellQuote(thisRef.serviceSpec.Executable)
+	for _, arg := range thisRef.serviceSpec.Args {
+		command += " " + shellQuote(arg)
+	}
+	command += cronRedirects(thisRef.serviceSpec.Logging)
+	if len(thisRef.serviceSpec.WorkingDirectory) > 0 {
+		command = "cd " + shellQuote(thisRef.serviceSpec.WorkingDirectory) + " && " + command
+	}
+	if thisRef.opts.limits != nil {
+		command = strings.Join(append(shellLimitCommands(*thisRef.opts.limits), command), " && ")
+	}
+
+	// INFO: `%` is a newline for cron
+	sb.WriteString(fmt.Sprintf("%s %s %s\n", timeFields, user, strings.Replace(command, "%", "\\%", -1)))
+
+	return sb.String(), nil
+}
+
+// setEnabled - comments the job line out or back in, the rest of the entry stays as it is
+func (thisRef cronService) setEnabled(ctx context.Context, isEnabled bool) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	fileContent, err := ioutil.ReadFile(thisRef.filePath())
+	if err != nil {
+		return ErrServiceDoesNotExist
+	}
+
+	// INFO: loaded by name there is no schedule to compare with, the shape of the line has to do
+	timeFields := ""
+	if thisRef.opts.schedule != nil {
+		timeFields, _ = thisRef.opts.schedule.cron()
+	}
+
+	lines := strings.Split(string(fileContent), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) <= 0 {
+			continue
+		}
+
+		if isEnabled && strings.HasPrefix(trimmed, cronDisabledMarker) && isCronJobLine(strings.TrimPrefix(trimmed, cronDisabledMarker), timeFields) {
+			lines[i] = strings.TrimPrefix(trimmed, cronDisabledMarker)
+		} else if !isEnabled && isCronJobLine(trimmed, timeFields) {
+			lines[i] = cronDisabledMarker + trimmed
+		}
+	}
+
+	logging.Debugf("%s: setting enabled to %v: %s", logTagCron, isEnabled, thisRef.filePath())
+
+	return ioutil.WriteFile(thisRef.filePath(), []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// isCronJobLine - whether `line` is the job line `fileContent()` writes, `<5 time fields> <user> <command>`,
+// its time fields are `timeFields` if that is known, ex: `*/15 * * * *`
+func isCronJobLine(line string, timeFields string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 7 || strings.HasPrefix(line, "#") {
+		return false
+	}
+
+	if len(timeFields) > 0 {
+		return strings.Join(fields[:5], " ") == timeFields
+	}
+
+	for _, field := range fields[:5] {
+		if strings.Trim(field, "0123456789*/,-") != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cronRedirects - appends stdout and stderr to the files in `logging`, ex: ` >> '/var/log/backup.log' 2>&1`,
+// without a file cron mails what the job printed as it always did
+func cronRedirects(logging spec.LoggingConfig) string {
+	result := ""
+
+	stdOut := ""
+	if !logging.StdOut.Disabled && len(logging.StdOut.Value) > 0 {
+		stdOut = logging.StdOut.Value
+		result += " >> " + shellQuote(stdOut)
+	}
+
+	if !logging.StdErr.Disabled && len(logging.StdErr.Value) > 0 {
+		if logging.StdErr.Value == stdOut {
+			result += " 2>&1"
+		} else {
+			result += " 2>> " + shellQuote(logging.StdErr.Value)
+		}
+	}
+
+	return result
+}
+
+// cronLoggingFromEntry - `SERVICE.Logging` back from the redirects `cronRedirects()` put on the job line
+func cronLoggingFromEntry(fileContent string) spec.LoggingConfig {
+	result := spec.LoggingConfig{}
+
+	for _, line := range strings.Split(fileContent, "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), cronDisabledMarker)
+		if !isCronJobLine(line, "") {
+			continue
+		}
+
+		// INFO: `%` is a newline for cron, `fileContent()` escapes it
+		line = strings.Replace(line, "\\%", "%", -1)
+		for _, match := range cronRedirectRegex.FindAllStringSubmatch(line, -1) {
+			path := strings.Replace(match[2], `'\''`, "'", -1)
+			if match[1] == "2" {
+				result.StdErr.Value = path
+			} else {
+				result.StdOut.Value = path
+			}
+		}
+
+		if strings.HasSuffix(line, " 2>&1") {
+			result.StdErr.Value = result.StdOut.Value
+		}
+	}
+
+	return result
+}
+
+// shellQuote - single quotes for `/bin/sh`, cron hands the command line to it
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
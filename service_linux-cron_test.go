@@ -0,0 +1,194 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestCronEnableKeepsHandEdits(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-cron")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	backupSpec := spec.NewEmptySERVICE()
+	backupSpec.Name = "backup"
+	backupSpec.Executable = "/usr/bin/backup"
+
+	opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemV)}
+
+	backup, err := NewServiceFromSERVICE(backupSpec, append(opts, WithSchedule(Schedule{Every: 15 * time.Minute}))...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := backup.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// INFO: a blank line with spaces and an environment line with spaces around `=`, both valid for cron
+	filePath := filepath.Join(root, "/etc/cron.d/backup")
+	fileContent, _ := ioutil.ReadFile(filePath)
+	ioutil.WriteFile(filePath, []byte("MAILTO = ops@example.com\n  \t \n"+string(fileContent)), 0644)
+
+	for _, service := range []Service{backup, mustLoadCron(t, "backup", opts)} {
+		if err := service.Disable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		fileContent, _ = ioutil.ReadFile(filePath)
+		if !strings.HasPrefix(string(fileContent), "MAILTO = ops@example.com\n  \t \n") {
+			t.Errorf("expected the environment line untouched, got:\n%s", fileContent)
+		}
+		if !strings.Contains(string(fileContent), cronDisabledMarker+"*/15 * * * * root '/usr/bin/backup'") {
+			t.Errorf("expected the job line disabled, got:\n%s", fileContent)
+		}
+		if strings.Count(string(fileContent), cronDisabledMarker) != 1 {
+			t.Errorf("expected only the job line disabled, got:\n%s", fileContent)
+		}
+
+		if err := service.Enable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isEnabled, err := service.IsEnabled(); err != nil || !isEnabled {
+			t.Errorf("expected enabled, got %v, %v", isEnabled, err)
+		}
+	}
+
+	// INFO: cron runs the job, there is nothing to start or stop
+	for _, operation := range []func() error{backup.Start, backup.Stop, backup.Restart, backup.Reload} {
+		if err := operation(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected ErrServiceUnsupportedRequest, got %v", err)
+		}
+	}
+	if isEnabled, err := backup.IsEnabled(); err != nil || !isEnabled {
+		t.Errorf("expected the entry left enabled, got %v, %v", isEnabled, err)
+	}
+}
+
+func TestCronLogs(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-cron")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	workerSpec := spec.NewEmptySERVICE()
+	workerSpec.Name = "worker@"
+	workerSpec.Executable = "/usr/bin/worker"
+	workerSpec.Logging.StdOut = spec.LoggingConfigOut{Value: "/var/log/worker-%i.log"}
+	workerSpec.Logging.StdErr = spec.LoggingConfigOut{Value: "/var/log/worker-%i.log"}
+
+	opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemV), WithSchedule(Schedule{Every: time.Hour})}
+
+	template, err := NewServiceFromSERVICE(workerSpec, opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := template.(LogReader).Logs(time.Time{}, 0); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest without instances, got %v", err)
+	}
+
+	for _, instance := range []string{"a", "b"} {
+		service, err := template.(Templater).Instance(instance)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := service.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	fileContent, _ := ioutil.ReadFile(filepath.Join(root, "/etc/cron.d/worker_a"))
+	if !strings.Contains(string(fileContent), " root '/usr/bin/worker' >> '/var/log/worker-a.log' 2>&1\n") {
+		t.Errorf("expected the output appended to the log file, got:\n%s", fileContent)
+	}
+
+	os.MkdirAll(filepath.Join(root, "/var/log"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(root, "/var/log/worker-a.log"), []byte("2021/01/26 10:00:00 a ran\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "/var/log/worker-b.log"), []byte("2021/01/26 09:00:00 b ran\n"), 0644)
+
+	// INFO: loaded by name, the log files come from the job line
+	tenantA, err := NewServiceFromName("worker@a", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines, err := tenantA.(LogReader).Logs(time.Time{}, 0)
+	if err != nil || len(lines) != 1 || lines[0].Text != "2021/01/26 10:00:00 a ran" {
+		t.Errorf("expected the line of the instance, got %+v, %v", lines, err)
+	}
+
+	fromName, err := NewServiceFromName("worker@", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines, err = fromName.(LogReader).Logs(time.Time{}, 0)
+	if err != nil || len(lines) != 2 || lines[0].Text != "2021/01/26 09:00:00 b ran" {
+		t.Errorf("expected the lines of both instances, oldest first, got %+v, %v", lines, err)
+	}
+
+	// without a log file cron mails the output
+	backupSpec := spec.NewEmptySERVICE()
+	backupSpec.Name = "backup"
+	backupSpec.Executable = "/usr/bin/backup"
+
+	backup, err := NewServiceFromSERVICE(backupSpec, opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := backup.(LogReader).Logs(time.Time{}, 0); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest, got %v", err)
+	}
+}
+
+func TestCronLoggingFromEntry(t *testing.T) {
+	for _, testCase := range []struct {
+		logging  spec.LoggingConfig
+		expected spec.LoggingConfig
+	}{
+		{spec.LoggingConfig{}, spec.LoggingConfig{}},
+		{
+			spec.LoggingConfig{StdOut: spec.LoggingConfigOut{Value: "/var/log/backup.log"}, StdErr: spec.LoggingConfigOut{Value: "/var/log/backup.err"}},
+			spec.LoggingConfig{StdOut: spec.LoggingConfigOut{Value: "/var/log/backup.log"}, StdErr: spec.LoggingConfigOut{Value: "/var/log/backup.err"}},
+		},
+		{
+			spec.LoggingConfig{StdOut: spec.LoggingConfigOut{Disabled: true}, StdErr: spec.LoggingConfigOut{Value: "/var/log/it's 100%.err"}},
+			spec.LoggingConfig{StdErr: spec.LoggingConfigOut{Value: "/var/log/it's 100%.err"}},
+		},
+	} {
+		backupSpec := spec.NewEmptySERVICE()
+		backupSpec.Name = "backup"
+		backupSpec.Executable = "/usr/bin/backup"
+		backupSpec.Logging = testCase.logging
+
+		backup := cronService{serviceSpec: backupSpec, opts: newOptions([]Option{WithSchedule(Schedule{Every: time.Hour})})}
+		fileContent, err := backup.fileContent()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, entry := range []string{fileContent, strings.Replace(fileContent, "0 * * * *", cronDisabledMarker+"0 * * * *", 1)} {
+			if logging := cronLoggingFromEntry(entry); logging != testCase.expected {
+				t.Errorf("expected %+v, got %+v from:\n%s", testCase.expected, logging, entry)
+			}
+		}
+	}
+}
+
+func mustLoadCron(t *testing.T, name string, opts []Option) Service {
+	service, err := newServiceFromName_Cron(name, newOptions(opts))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return service
+}
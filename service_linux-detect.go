@@ -0,0 +1,203 @@
+// +build linux
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// initBinaries - the name of PID 1's binary, once symlinks are resolved, for init systems that have their own
+var initBinaries = []struct {
+	marker   string
+	initType spec.InitType
+}{
+	{"systemd", spec.InitSystemd},
+	{"openrc-init", InitOpenRC},
+	{"runit", InitRunit},
+	{"busybox", InitBusybox},
+	{"upstart", spec.InitUpstart},
+}
+
+// containerMarkers - substrings of `/proc/1/cgroup` that only show up inside a container
+var containerMarkers = []string{"docker", "kubepods", "lxc", "containerd", "libpod"}
+
+// userSystemdStates - what `systemctl --user is-system-running` says when there is a manager to talk to
+var userSystemdStates = []string{"initializing", "starting", "running", "degraded", "maintenance", "stopping"}
+
+// detectInitSystem - looks at the machine whose filesystem is mounted at `root`, commands go through `executor`
+func detectInitSystem(ctx context.Context, root string, executor Executor) InitSystemReport {
+	result := InitSystemReport{}
+
+	// 1.
+	result.Type = detectInitType(ctx, root, executor, &result)
+
+	// 2.
+	result.InContainer = detectContainer(root, &result)
+
+	// 3.
+	if result.Type == spec.InitSystemd {
+		result.UserSystemdReachable = detectUserSystemd(ctx, executor, &result)
+	}
+
+	logging.Debugf("%s: detected init system: %s, evidence: %s", logTag, result.Type, strings.Join(result.Evidence, "; "))
+
+	return result
+}
+
+func detectInitType(ctx context.Context, root string, executor Executor, report *InitSystemReport) spec.InitType {
+	// 1. same check as `sd_booted()`
+	if info, err := os.Stat(filepath.Join(root, "/run/systemd/system")); err == nil && info.IsDir() {
+		report.addEvidence("/run/systemd/system exists")
+		return spec.InitSystemd
+	}
+
+	// 2. what PID 1 is
+	pid1Exe, exeErr := os.Readlink(filepath.Join(root, "/proc/1/exe"))
+	if exeErr == nil {
+		report.addEvidence("/proc/1/exe -> " + pid1Exe)
+	}
+
+	pid1Args := []string{}
+	cmdline, cmdlineErr := ioutil.ReadFile(filepath.Join(root, "/proc/1/cmdline"))
+	if cmdlineErr == nil {
+		// trim any nul bytes, this is present with some kernels
+		pid1Args = strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+		report.addEvidence("/proc/1/cmdline: " + strings.Join(pid1Args, " "))
+	}
+
+	if exeErr != nil && cmdlineErr != nil {
+		report.addEvidence("can't read /proc/1: " + cmdlineErr.Error())
+		return spec.InitUknown
+	}
+
+	// INFO: sysvinit rewrites its own command line to show the runlevel, ex: `init [2]`
+	if strings.HasPrefix(strings.Join(pid1Args, " "), "init [") {
+		return spec.InitSystemV
+	}
+
+	// 3. the binary, not the name it was started with, debian links `/sbin/init` to systemd
+	pid1Binaries := []string{}
+	if exeErr == nil {
+		pid1Binaries = append(pid1Binaries, pid1Exe)
+	}
+	if len(pid1Args) > 0 && len(pid1Args[0]) > 0 {
+		pid1Binaries = append(pid1Binaries, resolveInRoot(root, pid1Args[0], report))
+	}
+
+	isInit := false
+	for _, pid1Binary := range pid1Binaries {
+		name := filepath.Base(pid1Binary)
+		for _, initBinary := range initBinaries {
+			if strings.Contains(name, initBinary.marker) {
+				return initBinary.initType
+			}
+		}
+
+		// INFO: not `Contains()`, container inits like `tini` or `dumb-init` don't manage services
+		if name == "init" {
+			isInit = true
+		}
+	}
+
+	if !isInit {
+		// INFO: images with init scripts still run them through `service`, as before PID 1 was looked at this closely
+		if info, err := os.Stat(filepath.Join(root, "/etc/init.d")); err == nil && info.IsDir() {
+			report.addEvidence("PID 1 is not an init system, /etc/init.d exists, assuming sysvinit")
+			return spec.InitSystemV
+		}
+
+		report.addEvidence("PID 1 is not an init system")
+		return spec.InitUknown
+	}
+
+	// 4. a generic `init`, upstart, openrc on top of sysvinit or plain sysvinit
+	if output, err := executor.Exec(ctx, "initctl", "version"); err == nil && strings.Contains(output, "upstart") {
+		report.addEvidence("initctl version: " + strings.TrimSpace(output))
+		return spec.InitUpstart
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "/run/openrc")); err == nil {
+		report.addEvidence("/run/openrc exists")
+		return InitOpenRC
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "/etc/inittab")); err == nil {
+		report.addEvidence("/etc/inittab exists")
+		return spec.InitSystemV
+	}
+
+	report.addEvidence("PID 1 is a generic init, assuming sysvinit")
+	return spec.InitSystemV
+}
+
+// resolveInRoot - follows symlinks the way `filepath.EvalSymlinks()` does, with absolute targets inside `root`
+func resolveInRoot(root string, path string, report *InitSystemReport) string {
+	for i := 0; i < 8; i++ {
+		target, err := os.Readlink(filepath.Join(root, path))
+		if err != nil {
+			break
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		report.addEvidence(path + " -> " + target)
+		path = target
+	}
+
+	return path
+}
+
+func detectContainer(root string, report *InitSystemReport) bool {
+	for _, markerFile := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(filepath.Join(root, markerFile)); err == nil {
+			report.addEvidence(markerFile + " exists")
+			return true
+		}
+	}
+
+	if cgroup, err := ioutil.ReadFile(filepath.Join(root, "/proc/1/cgroup")); err == nil {
+		for _, marker := range containerMarkers {
+			if strings.Contains(string(cgroup), marker) {
+				report.addEvidence("/proc/1/cgroup mentions " + marker)
+				return true
+			}
+		}
+	}
+
+	// INFO: systemd-nspawn, podman and lxc set it for PID 1
+	if environ, err := ioutil.ReadFile(filepath.Join(root, "/proc/1/environ")); err == nil {
+		for _, variable := range strings.Split(string(environ), "\x00") {
+			if strings.HasPrefix(variable, "container=") {
+				report.addEvidence("PID 1 has " + variable)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func detectUserSystemd(ctx context.Context, executor Executor, report *InitSystemReport) bool {
+	// INFO: the exit code is not enough, `degraded` fails and still has a manager to talk to
+	output, _ := executor.Exec(ctx, "systemctl", "--user", "is-system-running")
+	state := strings.TrimSpace(output)
+	report.addEvidence("systemctl --user is-system-running: " + state)
+
+	for _, userSystemdState := range userSystemdStates {
+		if state == userSystemdState {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,272 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// fakeRoot - a machine made of files, symlinks and command outputs
+type fakeRoot struct {
+	files    map[string]string // path -> content, a trailing `/` makes a folder
+	symlinks map[string]string // path -> target
+	commands map[string]string // command line -> output, anything else fails
+}
+
+func (thisRef fakeRoot) build(t *testing.T) string {
+	root, err := ioutil.TempDir("", "systemkit-detect")
+	if err != nil {
+		t.Fatalf("can't create fake root: %v", err)
+	}
+
+	for path, content := range thisRef.files {
+		fullPath := filepath.Join(root, path)
+		if strings.HasSuffix(path, "/") {
+			os.MkdirAll(fullPath, os.ModePerm)
+			continue
+		}
+
+		os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+		if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("can't write %s: %v", path, err)
+		}
+	}
+
+	for path, target := range thisRef.symlinks {
+		fullPath := filepath.Join(root, path)
+		os.MkdirAll(filepath.Dir(fullPath), os.ModePerm)
+		if err := os.Symlink(target, fullPath); err != nil {
+			t.Fatalf("can't link %s: %v", path, err)
+		}
+	}
+
+	return root
+}
+
+func (thisRef fakeRoot) executor() Executor {
+	return ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		if output, ok := thisRef.commands[strings.Join(append([]string{name}, args...), " ")]; ok {
+			return output, nil
+		}
+
+		return "", errors.New("exit status 1")
+	})
+}
+
+func TestDetectInitSystem(t *testing.T) {
+	tests := []struct {
+		name                 string
+		root                 fakeRoot
+		wantType             spec.InitType
+		wantInContainer      bool
+		wantUserSystemd      bool
+		wantEvidenceContains string
+	}{
+		{
+			name: "booted with systemd",
+			root: fakeRoot{
+				files:    map[string]string{"/run/systemd/system/": "", "/proc/1/cmdline": "/sbin/init\x00splash\x00"},
+				commands: map[string]string{"systemctl --user is-system-running": "degraded\n"},
+			},
+			wantType:             spec.InitSystemd,
+			wantUserSystemd:      true,
+			wantEvidenceContains: "/run/systemd/system exists",
+		},
+		{
+			name: "systemd without a user manager",
+			root: fakeRoot{
+				files:    map[string]string{"/run/systemd/system/": ""},
+				commands: map[string]string{"systemctl --user is-system-running": "offline\n"},
+			},
+			wantType: spec.InitSystemd,
+		},
+		{
+			name: "debian links /sbin/init to systemd",
+			root: fakeRoot{
+				files:    map[string]string{"/proc/1/cmdline": "/sbin/init\x00"},
+				symlinks: map[string]string{"/sbin/init": "/lib/systemd/systemd"},
+			},
+			wantType:             spec.InitSystemd,
+			wantEvidenceContains: "/sbin/init -> /lib/systemd/systemd",
+		},
+		{
+			name: "sysvinit shows the runlevel",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "init [2]  \x00"},
+			},
+			wantType: spec.InitSystemV,
+		},
+		{
+			name: "upstart answers initctl",
+			root: fakeRoot{
+				files:    map[string]string{"/proc/1/cmdline": "/sbin/init\x00"},
+				symlinks: map[string]string{"/proc/1/exe": "/sbin/init"},
+				commands: map[string]string{"initctl version": "init (upstart 1.12.1)\n"},
+			},
+			wantType:             spec.InitUpstart,
+			wantEvidenceContains: "initctl version: init (upstart 1.12.1)",
+		},
+		{
+			name: "openrc-init",
+			root: fakeRoot{
+				files:    map[string]string{"/proc/1/cmdline": "/sbin/init\x00"},
+				symlinks: map[string]string{"/proc/1/exe": "/sbin/openrc-init"},
+			},
+			wantType:             InitOpenRC,
+			wantEvidenceContains: "/proc/1/exe -> /sbin/openrc-init",
+		},
+		{
+			name: "openrc on top of sysvinit",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "init\x00", "/run/openrc/": "", "/etc/inittab": ""},
+			},
+			wantType: InitOpenRC,
+		},
+		{
+			name: "runit",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "runit\x00"},
+			},
+			wantType: InitRunit,
+		},
+		{
+			name: "busybox",
+			root: fakeRoot{
+				files:    map[string]string{"/proc/1/cmdline": "init\x00"},
+				symlinks: map[string]string{"/proc/1/exe": "/bin/busybox"},
+			},
+			wantType: InitBusybox,
+		},
+		{
+			name: "generic init with an inittab",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "/sbin/init\x00", "/etc/inittab": ""},
+			},
+			wantType:             spec.InitSystemV,
+			wantEvidenceContains: "/etc/inittab exists",
+		},
+		{
+			name: "docker container running a shell",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "/bin/sh\x00-c\x00sleep infinity\x00", "/.dockerenv": ""},
+			},
+			wantType:             spec.InitUknown,
+			wantInContainer:      true,
+			wantEvidenceContains: "PID 1 is not an init system",
+		},
+		{
+			name: "kubernetes pod with tini",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "/sbin/tini\x00--\x00app\x00", "/proc/1/cgroup": "0::/kubepods/besteffort/pod1234\n"},
+			},
+			wantType:        spec.InitUknown,
+			wantInContainer: true,
+		},
+		{
+			name: "docker container with tini and init scripts",
+			root: fakeRoot{
+				files: map[string]string{"/proc/1/cmdline": "/usr/bin/tini\x00--\x00/docker-entrypoint.sh\x00", "/.dockerenv": "", "/etc/init.d/": ""},
+			},
+			wantType:             spec.InitSystemV,
+			wantInContainer:      true,
+			wantEvidenceContains: "/etc/init.d exists",
+		},
+		{
+			name: "systemd-nspawn",
+			root: fakeRoot{
+				files: map[string]string{"/run/systemd/system/": "", "/proc/1/environ": "PATH=/bin\x00container=systemd-nspawn\x00"},
+			},
+			wantType:        spec.InitSystemd,
+			wantInContainer: true,
+		},
+		{
+			name:                 "no /proc",
+			root:                 fakeRoot{},
+			wantType:             spec.InitUknown,
+			wantEvidenceContains: "can't read /proc/1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := test.root.build(t)
+			defer os.RemoveAll(root)
+
+			got := detectInitSystem(context.Background(), root, test.root.executor())
+
+			if got.Type != test.wantType {
+				t.Errorf("Type: expected %s, got %s, evidence: %v", test.wantType, got.Type, got.Evidence)
+			}
+			if got.InContainer != test.wantInContainer {
+				t.Errorf("InContainer: expected %v, got %v, evidence: %v", test.wantInContainer, got.InContainer, got.Evidence)
+			}
+			if got.UserSystemdReachable != test.wantUserSystemd {
+				t.Errorf("UserSystemdReachable: expected %v, got %v", test.wantUserSystemd, got.UserSystemdReachable)
+			}
+			if len(test.wantEvidenceContains) > 0 && !strings.Contains(strings.Join(got.Evidence, "\n"), test.wantEvidenceContains) {
+				t.Errorf("Evidence: expected %q in %v", test.wantEvidenceContains, got.Evidence)
+			}
+		})
+	}
+}
+
+func TestInitTypeFor(t *testing.T) {
+	previous, hadPrevious := os.LookupEnv(initTypeEnvVar)
+	defer func() {
+		if hadPrevious {
+			os.Setenv(initTypeEnvVar, previous)
+		} else {
+			os.Unsetenv(initTypeEnvVar)
+		}
+	}()
+
+	for _, test := range []struct {
+		env      string
+		opts     []Option
+		wantType spec.InitType
+		wantErr  error
+	}{
+		{env: "upstart", wantType: spec.InitUpstart},
+		{env: " systemd\n", wantType: spec.InitSystemd},
+		{env: "upstart", opts: []Option{WithInitType(spec.InitSystemV)}, wantType: spec.InitSystemV},
+		{env: "sysv", opts: []Option{WithInitType(spec.InitSystemd)}, wantType: spec.InitSystemd},
+		{env: "sysv", wantType: spec.InitUknown, wantErr: ErrServiceConfigError},
+		{env: "runit", wantType: InitRunit},
+	} {
+		os.Setenv(initTypeEnvVar, test.env)
+
+		got, err := initTypeFor(newOptions(test.opts))
+		if got != test.wantType || !errors.Is(err, test.wantErr) {
+			t.Errorf("%s=%q: expected %s, %v, got %s, %v", initTypeEnvVar, test.env, test.wantType, test.wantErr, got, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), initTypeEnvVar) {
+			t.Errorf("%s=%q: expected the variable named in %q", initTypeEnvVar, test.env, err.Error())
+		}
+	}
+
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+
+	for env, wantErr := range map[string]error{"sysv": ErrServiceConfigError, "openrc": ErrServiceUnsupportedRequest, "busybox": ErrServiceUnsupportedRequest} {
+		os.Setenv(initTypeEnvVar, env)
+
+		if _, err := NewServiceFromSERVICE(daemonSpec); !errors.Is(err, wantErr) {
+			t.Errorf("NewServiceFromSERVICE(), %s=%s: expected %v, got %v", initTypeEnvVar, env, wantErr, err)
+		}
+		if _, err := NewServiceFromName("daemon"); !errors.Is(err, wantErr) {
+			t.Errorf("NewServiceFromName(), %s=%s: expected %v, got %v", initTypeEnvVar, env, wantErr, err)
+		}
+		if _, err := NewServiceFromSERVICE(daemonSpec, WithSchedule(Schedule{DailyAt: "03:00"})); !errors.Is(err, wantErr) {
+			t.Errorf("scheduled, %s=%s: expected %v, got %v", initTypeEnvVar, env, wantErr, err)
+		}
+	}
+}
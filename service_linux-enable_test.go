@@ -0,0 +1,222 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemvRCLinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-rc")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+
+	links := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(root, "/etc/rc?.d/*daemon"))
+		sort.Strings(matches)
+
+		result := []string{}
+		for _, match := range matches {
+			result = append(result, strings.TrimPrefix(match, root))
+		}
+		return result
+	}
+
+	daemon := newServiceFromSERVICE_SystemV(daemonSpec, newOptions([]Option{WithRoot(root), WithEnableOnStart()}))
+
+	// INFO: installing enables, the runlevel folders don't exist yet
+	if err := daemon.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "/etc/rc0.d/K02daemon /etc/rc1.d/K02daemon /etc/rc2.d/S50daemon /etc/rc3.d/S50daemon /etc/rc4.d/S50daemon /etc/rc5.d/S50daemon /etc/rc6.d/K02daemon"
+	if strings.Join(links(), " ") != expected {
+		t.Errorf("unexpected links: %v", links())
+	}
+	if target, err := os.Readlink(filepath.Join(root, "/etc/rc2.d/S50daemon")); err != nil || target != "/etc/init.d/daemon" {
+		t.Errorf("expected a link to the script as seen in the image, got %q, %v", target, err)
+	}
+
+	// offline Stop and Start only touch the links
+	if err := daemon.Stop(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(links()) > 0 {
+		t.Errorf("expected the links removed, got %v", links())
+	}
+	if isEnabled, err := daemon.IsEnabled(); err != nil || isEnabled {
+		t.Errorf("expected disabled, got %v, %v", isEnabled, err)
+	}
+
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if isEnabled, err := daemon.IsEnabled(); err != nil || !isEnabled {
+		t.Errorf("expected enabled, got %v, %v", isEnabled, err)
+	}
+
+	// links someone else made, at other priorities, go too
+	os.Symlink("/etc/init.d/daemon", filepath.Join(root, "/etc/rc2.d/S20daemon"))
+	if err := daemon.Disable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(links()) > 0 {
+		t.Errorf("expected the links removed, got %v", links())
+	}
+
+	if err := daemon.Uninstall(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "/etc/init.d/daemon")); !os.IsNotExist(err) {
+		t.Errorf("expected the script removed, got %v", err)
+	}
+
+	coupled := newServiceFromSERVICE_SystemV(daemonSpec, newOptions([]Option{WithRoot(root)}))
+	if err := coupled.Start(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest starting offline, got %v", err)
+	}
+}
+
+func TestSystemvEnableFailure(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-rc")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+
+	daemon := newServiceFromSERVICE_SystemV(daemonSpec, newOptions([]Option{WithRoot(root)}))
+
+	// installing enables, the links made then go with their folders
+	if err := daemon.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// INFO: root writes into a read-only folder anyway, a file where a runlevel folder should be stops it too
+	os.RemoveAll(filepath.Join(root, "/etc/rc2.d"))
+	os.RemoveAll(filepath.Join(root, "/etc/rc3.d"))
+	os.MkdirAll(filepath.Join(root, "/etc/rc2.d"), 0555)
+	defer os.Chmod(filepath.Join(root, "/etc/rc2.d"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "/etc/rc3.d"), []byte{}, 0644)
+
+	err = daemon.Enable()
+
+	var operationError *OperationError
+	if !errors.As(err, &operationError) {
+		t.Fatalf("expected an OperationError, got %v", err)
+	}
+	if operationError.Backend != string(spec.InitSystemV) || operationError.Operation != "enable" {
+		t.Errorf("unexpected OperationError: %+v", operationError)
+	}
+	if os.Geteuid() != 0 && !errors.Is(err, ErrServicePermissionDenied) {
+		t.Errorf("expected ErrServicePermissionDenied, got %v", err)
+	}
+}
+
+func TestUpstartOverrideManual(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-override")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+	daemonSpec.Start.AtBoot = true
+
+	daemon := newServiceFromSERVICE_Upstart(daemonSpec, newOptions([]Option{WithRoot(root), WithEnableOnStart()}))
+	if err := daemon.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	overridePath := filepath.Join(root, "/etc/init/daemon.override")
+	if isEnabled, err := daemon.IsEnabled(); err != nil || !isEnabled {
+		t.Errorf("expected a job that starts on boot enabled, got %v, %v", isEnabled, err)
+	}
+
+	// a hand written stanza in the override stays through disable and enable
+	ioutil.WriteFile(overridePath, []byte("env DEBUG=1\n"), 0644)
+
+	if err := daemon.Stop(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if override, _ := ioutil.ReadFile(overridePath); string(override) != "env DEBUG=1\nmanual\n" {
+		t.Errorf("expected manual added, got:\n%s", override)
+	}
+	if isEnabled, err := daemon.IsEnabled(); err != nil || isEnabled {
+		t.Errorf("expected disabled, got %v, %v", isEnabled, err)
+	}
+
+	// twice is the same as once
+	if err := daemon.Disable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if override, _ := ioutil.ReadFile(overridePath); strings.Count(string(override), "manual") != 1 {
+		t.Errorf("expected a single manual, got:\n%s", override)
+	}
+
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if override, _ := ioutil.ReadFile(overridePath); string(override) != "env DEBUG=1\n" {
+		t.Errorf("expected manual removed, got:\n%s", override)
+	}
+
+	// nothing but manual, the override goes with it
+	os.Remove(overridePath)
+	if err := daemon.Disable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := daemon.Enable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(overridePath); !os.IsNotExist(err) {
+		t.Errorf("expected the override removed, got %v", err)
+	}
+
+	if err := daemon.Disable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := daemon.Uninstall(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(overridePath); !os.IsNotExist(err) {
+		t.Errorf("expected the override removed with the job, got %v", err)
+	}
+}
+
+func TestUpstartManualStanza(t *testing.T) {
+	for _, testCase := range []struct {
+		jobContent string
+		expected   bool
+	}{
+		{"", false},
+		{"manual\n", true},
+		{"env DEBUG=1\n  manual  \n", true},
+		{"start on runlevel [2345]\n", false},
+		{"# manual\n", false},
+		{"env MODE=manual\n", false},
+	} {
+		if hasManualStanza(testCase.jobContent) != testCase.expected {
+			t.Errorf("%q: expected %v", testCase.jobContent, testCase.expected)
+		}
+	}
+}
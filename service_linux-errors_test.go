@@ -0,0 +1,51 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestOperationErrorsThroughExecutor(t *testing.T) {
+	missingSpec := spec.NewEmptySERVICE()
+	missingSpec.Name = "missing"
+	missingSpec.Executable = "/usr/bin/missing"
+
+	for _, testCase := range []struct {
+		initType spec.InitType
+		output   string
+	}{
+		{spec.InitSystemd, "Failed to stop missing.service: Unit missing.service not loaded.\n"},
+		{spec.InitSystemV, "missing: unrecognized service\n"},
+		{spec.InitUpstart, "initctl: Unknown job: missing\n"},
+	} {
+		executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+			return testCase.output, errors.New("exit status 1")
+		})
+
+		opts := newOptions([]Option{WithExecutor(executor)})
+		missing := map[spec.InitType]Service{
+			spec.InitSystemd: newServiceFromSERVICE_SystemD(missingSpec, opts),
+			spec.InitSystemV: newServiceFromSERVICE_SystemV(missingSpec, opts),
+			spec.InitUpstart: newServiceFromSERVICE_Upstart(missingSpec, opts),
+		}[testCase.initType]
+
+		err := missing.Stop()
+		if !errors.Is(err, ErrServiceDoesNotExist) {
+			t.Errorf("%s: expected ErrServiceDoesNotExist, got %v", testCase.initType, err)
+		}
+
+		var operationError *OperationError
+		if !errors.As(err, &operationError) {
+			t.Errorf("%s: expected an OperationError, got %T", testCase.initType, err)
+			continue
+		}
+		if operationError.Backend != string(testCase.initType) || operationError.Output != testCase.output || len(operationError.Command) <= 0 {
+			t.Errorf("%s: unexpected OperationError: %+v", testCase.initType, operationError)
+		}
+	}
+}
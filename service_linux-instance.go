@@ -0,0 +1,318 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+var logTagInstance = "INSTANCE-SERVICE"
+
+// emulatedTemplateService - a template on SysV and Upstart, they have none of their own.
+// Nothing is installed for the template itself, each instance gets its own script, job or cron entry
+// with the instance name filled in for `%i` and `%I` and exported as `$INSTANCE`.
+type emulatedTemplateService struct {
+	serviceSpec        spec.SERVICE
+	opts               options
+	instancePattern    string // where the installed instances are, ex: `/etc/init.d/worker@*`
+	instanceFilePrefix string // ex: `worker_` for cron entries
+	instanceSuffix     string // ex: `.conf` for Upstart jobs
+	newInstance        func(instance string) (Service, error)
+	instanceFromName   func(name string, opts options) (Service, error)
+}
+
+func newEmulatedTemplateFromSERVICE(initType spec.InitType, serviceSpec spec.SERVICE, opts options) Service {
+	result := newEmulatedTemplate(initType, serviceSpec.Name, opts)
+	result.serviceSpec = serviceSpec
+	result.newInstance = func(instance string) (Service, error) {
+		instanceSpec := instanceSERVICE(serviceSpec, instance)
+		if opts.schedule != nil {
+			return newServiceFromSERVICE_Cron(instanceSpec, opts), nil
+		}
+
+		if initType == spec.InitUpstart {
+			return newEmulatedInstance(newServiceFromSERVICE_Upstart(instanceSpec, opts), instance), nil
+		}
+
+		return newEmulatedInstance(newServiceFromSERVICE_SystemV(instanceSpec, opts), instance), nil
+	}
+
+	return result
+}
+
+// newEmulatedTemplateFromName - nothing is kept for the template, its instances are the ones already installed
+func newEmulatedTemplateFromName(initType spec.InitType, name string, opts options) Service {
+	result := newEmulatedTemplate(initType, name, opts)
+	result.newInstance = func(instance string) (Service, error) {
+		return result.instanceFromName(name+instance, opts)
+	}
+
+	return result
+}
+
+// newEmulatedTemplateFromPlatformTemplate - `%i` and `%I` in the script or job are replaced for each instance
+func newEmulatedTemplateFromPlatformTemplate(initType spec.InitType, name string, template string, opts options) Service {
+	result := newEmulatedTemplate(initType, name, opts)
+	result.newInstance = func(instance string) (Service, error) {
+		instanceTemplate := template
+		for _, specifier := range instanceSpecifiers {
+			instanceTemplate = strings.Replace(instanceTemplate, specifier, instance, -1)
+		}
+
+		var service Service
+		var err error
+		if initType == spec.InitUpstart {
+			service, err = newServiceFromPlatformTemplate_Upstart(name+instance, instanceTemplate, opts)
+		} else {
+			service, err = newServiceFromPlatformTemplate_SystemV(name+instance, instanceTemplate, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return newEmulatedInstance(service, instance), nil
+	}
+
+	return result
+}
+
+// newEmulatedInstance - `service` exporting `$INSTANCE` to what it starts, the encoders leave the environment out
+func newEmulatedInstance(service Service, instance string) Service {
+	switch instanceService := service.(type) {
+	case *systemvService:
+		instanceService.instance = instance
+	case *upstartService:
+		instanceService.instance = instance
+	}
+
+	return service
+}
+
+func newEmulatedTemplate(initType spec.InitType, name string, opts options) *emulatedTemplateService {
+	logging.Debugf("%s: emulating template %s on %s", logTagInstance, name, initType)
+
+	result := &emulatedTemplateService{
+		serviceSpec:        spec.SERVICE{Name: name},
+		opts:               opts,
+		instancePattern:    opts.rooted(filepath.Join("/etc/init.d/", name+"*")),
+		instanceFilePrefix: name,
+		instanceFromName:   newServiceFromName_SystemV,
+	}
+
+	// INFO: scheduled, cron runs the instances whatever the init system
+	if opts.schedule != nil {
+		result.instanceFilePrefix = cronFileName(name)
+		result.instancePattern = opts.rooted(filepath.Join(cronDir, result.instanceFilePrefix+"*"))
+		result.instanceFromName = newServiceFromName_Cron
+	} else if initType == spec.InitUpstart {
+		result.instancePattern = opts.rooted(filepath.Join(upstartJobDir(opts), name+"*.conf"))
+		result.instanceSuffix = ".conf"
+		result.instanceFromName = newServiceFromName_Upstart
+	}
+
+	return result
+}
+
+func (thisRef emulatedTemplateService) Instance(instance string) (Service, error) {
+	if _, err := instanceName(thisRef.serviceSpec.Name, instance); err != nil {
+		return nil, err
+	}
+
+	return thisRef.newInstance(instance)
+}
+
+func (thisRef emulatedTemplateService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+// InstallContext - there is nothing to install until there is an instance
+func (thisRef emulatedTemplateService) InstallContext(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (thisRef emulatedTemplateService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+// UninstallContext - uninstalls every instance
+func (thisRef emulatedTemplateService) UninstallContext(ctx context.Context) error {
+	for _, name := range thisRef.installedInstances() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		logging.Debugf("%s: uninstalling instance %s", logTagInstance, name)
+
+		instance, err := thisRef.instanceFromName(name, thisRef.opts)
+		if err != nil {
+			continue
+		}
+
+		err = instance.UninstallContext(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (thisRef emulatedTemplateService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) StartContext(ctx context.Context) error {
+	return thisRef.errTemplate("start")
+}
+
+func (thisRef emulatedTemplateService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) StopContext(ctx context.Context) error {
+	return thisRef.errTemplate("stop")
+}
+
+func (thisRef emulatedTemplateService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) RestartContext(ctx context.Context) error {
+	return thisRef.errTemplate("restart")
+}
+
+func (thisRef emulatedTemplateService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) ReloadContext(ctx context.Context) error {
+	return thisRef.errTemplate("reload")
+}
+
+func (thisRef emulatedTemplateService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) EnableContext(ctx context.Context) error {
+	return thisRef.errTemplate("enable")
+}
+
+func (thisRef emulatedTemplateService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) DisableContext(ctx context.Context) error {
+	return thisRef.errTemplate("disable")
+}
+
+func (thisRef emulatedTemplateService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef emulatedTemplateService) IsEnabledContext(ctx context.Context) (bool, error) {
+	return false, thisRef.errTemplate("is-enabled")
+}
+
+func (thisRef emulatedTemplateService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+// InfoContext - a template never runs, only its instances do
+func (thisRef emulatedTemplateService) InfoContext(ctx context.Context) Info {
+	return Info{
+		Error:     nil,
+		Service:   thisRef.serviceSpec,
+		IsRunning: false,
+		PID:       -1,
+		Status:    Status{State: StateUnknown},
+	}
+}
+
+func (thisRef emulatedTemplateService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.LogsContext(context.Background(), since, limit)
+}
+
+// LogsContext - the lines of all the installed instances, oldest first
+func (thisRef emulatedTemplateService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return readLogFiles(paths, since, limit)
+}
+
+// FollowLogs - the lines of the instances installed when it is called
+func (thisRef emulatedTemplateService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return followLogFiles(ctx, paths), nil
+}
+
+// logFilePaths - the log files of every installed instance, the ones that don't log to a file are left out
+func (thisRef emulatedTemplateService) logFilePaths() ([]string, error) {
+	result := []string{}
+	for _, name := range thisRef.installedInstances() {
+		instance, err := thisRef.instanceFromName(name, thisRef.opts)
+		if err != nil {
+			continue
+		}
+
+		var paths []string
+		switch instanceService := instance.(type) {
+		case *systemvService:
+			paths = instanceService.logFilePaths()
+		case *upstartService:
+			paths, _ = instanceService.logFilePaths()
+		case *cronService:
+			paths, _ = instanceService.logFilePaths()
+		}
+
+		result = append(result, paths...)
+	}
+
+	if len(result) <= 0 {
+		return nil, fmt.Errorf("%w: %s has no instance that logs to a file", ErrServiceUnsupportedRequest, thisRef.serviceSpec.Name)
+	}
+
+	return result, nil
+}
+
+// installedInstances - names of the instances that have a script or job
+func (thisRef emulatedTemplateService) installedInstances() []string {
+	matches, _ := filepath.Glob(thisRef.instancePattern)
+	sort.Strings(matches)
+
+	result := []string{}
+	for _, match := range matches {
+		instance := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), thisRef.instanceFilePrefix), thisRef.instanceSuffix)
+		if len(instance) > 0 {
+			result = append(result, thisRef.serviceSpec.Name+instance)
+		}
+	}
+
+	return result
+}
+
+func (thisRef emulatedTemplateService) errTemplate(operation string) error {
+	return fmt.Errorf("%w: %s: %s is a template, use one of its instances", ErrServiceUnsupportedRequest, operation, thisRef.serviceSpec.Name)
+}
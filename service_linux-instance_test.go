@@ -0,0 +1,167 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestInstances(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-instance")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	workerSpec := spec.NewEmptySERVICE()
+	workerSpec.Name = "worker@"
+	workerSpec.Executable = "/usr/bin/worker"
+	workerSpec.Args = []string{"--tenant", "%i"}
+	workerSpec.Start.AtBoot = true
+
+	t.Run("systemd", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemd)}
+
+		template, err := NewServiceFromSERVICE(workerSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := template.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		tenantA, err := template.(Templater).Instance("tenantA")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tenantA.Enable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		link := filepath.Join(root, "/etc/systemd/system/multi-user.target.wants/worker@tenantA.service")
+		if target, err := os.Readlink(link); err != nil || target != "/etc/systemd/system/worker@.service" {
+			t.Errorf("expected the instance linked to the template, got %q, %v", target, err)
+		}
+
+		fromName, err := NewServiceFromName("worker@tenantA", opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isEnabled, err := fromName.IsEnabled(); err != nil || !isEnabled {
+			t.Errorf("expected the instance enabled, got %v, %v", isEnabled, err)
+		}
+
+		if err := tenantA.Uninstall(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Lstat(link); !os.IsNotExist(err) {
+			t.Errorf("expected the instance link removed, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "/etc/systemd/system/worker@.service")); err != nil {
+			t.Errorf("expected the template to stay installed, got %v", err)
+		}
+
+		if _, err := template.(Templater).Instance("a/b"); !errors.Is(err, ErrServiceConfigError) {
+			t.Errorf("expected ErrServiceConfigError for an invalid instance, got %v", err)
+		}
+	})
+
+	t.Run("systemv", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemV)}
+
+		template, err := NewServiceFromSERVICE(workerSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := template.Start(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected ErrServiceUnsupportedRequest starting a template, got %v", err)
+		}
+
+		tenantB, err := template.(Templater).Instance("tenantB")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tenantB.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		script, err := ioutil.ReadFile(filepath.Join(root, "/etc/init.d/worker@tenantB"))
+		if err != nil || !strings.Contains(string(script), "tenantB") || strings.Contains(string(script), "%i") {
+			t.Errorf("expected an init script with the instance filled in, got %v:\n%s", err, script)
+		}
+		if !strings.Contains(string(script), "\nINSTANCE='tenantB'\nexport INSTANCE\n") {
+			t.Errorf("expected $INSTANCE exported, got:\n%s", script)
+		}
+
+		if err := template.Uninstall(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "/etc/init.d/worker@tenantB")); !os.IsNotExist(err) {
+			t.Errorf("expected the instance uninstalled with the template, got %v", err)
+		}
+	})
+
+	t.Run("upstart", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitUpstart)}
+
+		template, err := NewServiceFromSERVICE(workerSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		tenantC, err := template.(Templater).Instance("tenantC")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tenantC.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		job, _ := ioutil.ReadFile(filepath.Join(root, "/etc/init/worker@tenantC.conf"))
+		if !strings.Contains(string(job), "\nenv INSTANCE=tenantC\n") {
+			t.Errorf("expected $INSTANCE set, got:\n%s", job)
+		}
+	})
+
+	t.Run("cron", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemV), WithSchedule(Schedule{Every: time.Hour})}
+
+		template, err := NewServiceFromSERVICE(workerSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		tenantD, err := template.(Templater).Instance("tenantD")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tenantD.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// INFO: cron skips entries with `@` in the name
+		entry, _ := ioutil.ReadFile(filepath.Join(root, "/etc/cron.d/worker_tenantD"))
+		if !strings.Contains(string(entry), "\nINSTANCE=tenantD\n") || !strings.Contains(string(entry), "'--tenant' 'tenantD'") {
+			t.Errorf("expected the instance filled in and $INSTANCE set, got:\n%s", entry)
+		}
+
+		fromName, err := NewServiceFromName("worker@", opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := fromName.Uninstall(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "/etc/cron.d/worker_tenantD")); !os.IsNotExist(err) {
+			t.Errorf("expected the instance uninstalled with the template, got %v", err)
+		}
+	})
+}
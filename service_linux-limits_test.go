@@ -0,0 +1,106 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestLimits(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-limits")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	crawlerSpec := spec.NewEmptySERVICE()
+	crawlerSpec.Name = "crawler"
+	crawlerSpec.Executable = "/usr/bin/crawler"
+
+	limits := Limits{MemoryMax: 512 * 1024 * 1024, CPUQuota: 150, TasksMax: 64, OpenFiles: 4096}
+
+	for _, testCase := range []struct {
+		initType spec.InitType
+		filePath string
+		expected []string
+	}{
+		{spec.InitSystemd, "/etc/systemd/system/crawler.service", []string{"MemoryMax=536870912\n", "CPUQuota=150%\n", "TasksMax=64\n", "LimitNOFILE=4096\n"}},
+		{spec.InitSystemV, "/etc/init.d/crawler", []string{"ulimit -n 4096\n", "ulimit -v 524288\n", "{ ulimit -u 64 || ulimit -p 64; } 2> /dev/null\n"}},
+		{spec.InitUpstart, "/etc/init/crawler.conf", []string{"limit nofile 4096 4096\n", "limit nproc 64 64\n", "limit as 536870912 536870912\n"}},
+	} {
+		crawler, err := NewServiceFromSERVICE(crawlerSpec, WithRoot(root), WithScope(ScopeSystem), WithInitType(testCase.initType), WithLimits(limits))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.initType, err)
+		}
+		if err := crawler.Install(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.initType, err)
+		}
+
+		fileContent, _ := ioutil.ReadFile(filepath.Join(root, testCase.filePath))
+		for _, expected := range testCase.expected {
+			if !strings.Contains(string(fileContent), expected) {
+				t.Errorf("%s: expected %q in:\n%s", testCase.initType, expected, fileContent)
+			}
+		}
+	}
+
+	// INFO: scheduled on SysV and Upstart, cron runs it
+	scheduled, err := NewServiceFromSERVICE(crawlerSpec, WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitUpstart), WithLimits(limits), WithSchedule(Schedule{Every: time.Hour}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := scheduled.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fileContent, _ := ioutil.ReadFile(filepath.Join(root, "/etc/cron.d/crawler")); !strings.Contains(string(fileContent), " root ulimit -n 4096 && ulimit -v 524288 && { ulimit -u 64 || ulimit -p 64; } 2> /dev/null && '/usr/bin/crawler'\n") {
+		t.Errorf("expected the limits in the job line, got:\n%s", fileContent)
+	}
+
+	if _, err := NewServiceFromSERVICE(crawlerSpec, WithRoot(root), WithInitType(spec.InitSystemd), WithLimits(Limits{CPUQuota: -1})); !errors.Is(err, ErrServiceConfigError) {
+		t.Errorf("expected ErrServiceConfigError, got %v", err)
+	}
+}
+
+func TestLimitsWarnings(t *testing.T) {
+	limits := Limits{MemoryMax: 512 * 1024 * 1024, CPUQuota: 150, OpenFiles: 4096}
+
+	if warnings := limits.warnings(string(spec.InitSystemd)); len(warnings) > 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+
+	for _, backend := range []string{string(spec.InitSystemV), string(spec.InitUpstart), "cron"} {
+		expected := []string{
+			backend + " approximates MemoryMax with a per-process limit, the service as a whole may use more",
+			backend + " can't limit CPUQuota, the service runs without this limit",
+		}
+		if warnings := limits.warnings(backend); strings.Join(warnings, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: unexpected warnings: %v", backend, warnings)
+		}
+	}
+}
+
+func TestSystemdLimitsUnitReplaces(t *testing.T) {
+	unit := "[Unit]\nDescription=crawler\n\n[Service]\nExecStart=/usr/bin/crawler\nLimitNOFILE=1024\n"
+
+	result := systemdLimitsUnit(unit, Limits{OpenFiles: 4096})
+	if result != "[Unit]\nDescription=crawler\n\n[Service]\nLimitNOFILE=4096\nExecStart=/usr/bin/crawler\n" {
+		t.Errorf("unexpected unit:\n%s", result)
+	}
+}
+
+func TestSystemvLimitsScriptPlacement(t *testing.T) {
+	script := "#!/bin/sh\n### BEGIN INIT INFO\n# Provides: crawler\n### END INIT INFO\nstart() {\n}\n"
+
+	result := systemvLimitsScript(script, Limits{OpenFiles: 4096})
+	if !strings.HasPrefix(result, "#!/bin/sh\n### BEGIN INIT INFO\n# Provides: crawler\n### END INIT INFO\n# resource limits\nulimit -n 4096\n\nstart()") {
+		t.Errorf("unexpected script:\n%s", result)
+	}
+}
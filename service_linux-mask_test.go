@@ -0,0 +1,193 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestMask(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-mask")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	agentSpec := spec.NewEmptySERVICE()
+	agentSpec.Name = "agent"
+	agentSpec.Executable = "/usr/bin/agent"
+	agentSpec.Start.AtBoot = true
+
+	t.Run("systemd", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemd)}
+
+		agent, err := NewServiceFromSERVICE(agentSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// INFO: installed here, so the mask goes where systemd looks first
+		if err := agent.(Masker).Mask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		maskLink := filepath.Join(root, "/etc/systemd/system.control/agent.service")
+		if target, err := os.Readlink(maskLink); err != nil || target != "/dev/null" {
+			t.Errorf("expected a mask, got %q, %v", target, err)
+		}
+
+		if info := agent.Info(); !info.IsMasked || info.Status.EnabledState != "masked" {
+			t.Errorf("expected masked, got %v, %s", info.IsMasked, info.Status.EnabledState)
+		}
+		if err := agent.Enable(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected a masked service not to enable, got %v", err)
+		}
+
+		fromName, err := NewServiceFromName("agent", opts...)
+		if err != nil {
+			t.Fatalf("expected a masked service to load, got %v", err)
+		}
+		if err := fromName.(Masker).Unmask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Lstat(maskLink); !os.IsNotExist(err) {
+			t.Errorf("expected the mask removed, got %v", err)
+		}
+		if agent.Info().IsMasked {
+			t.Errorf("expected unmasked")
+		}
+
+		// INFO: a mask in `.control` would mask whatever gets installed with this name next
+		if err := agent.(Masker).Mask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.Uninstall(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Lstat(maskLink); !os.IsNotExist(err) {
+			t.Errorf("expected the mask removed with the unit, got %v", err)
+		}
+
+		// vendor units are masked the way `systemctl mask` does it
+		os.MkdirAll(filepath.Join(root, "/usr/lib/systemd/system"), os.ModePerm)
+		ioutil.WriteFile(filepath.Join(root, "/usr/lib/systemd/system/cups.service"), []byte("[Service]\nExecStart=/usr/sbin/cupsd\n"), 0644)
+
+		cups, err := NewServiceFromName("cups", opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := cups.(Masker).Mask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if target, err := os.Readlink(filepath.Join(root, "/etc/systemd/system/cups.service")); err != nil || target != "/dev/null" {
+			t.Errorf("expected a mask, got %q, %v", target, err)
+		}
+		if err := cups.(Masker).Unmask(); err != nil || cups.Info().IsMasked {
+			t.Errorf("expected unmasked, got %v", err)
+		}
+	})
+
+	t.Run("systemv", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemV)}
+
+		agent, err := NewServiceFromSERVICE(agentSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original, _ := ioutil.ReadFile(filepath.Join(root, "/etc/init.d/agent"))
+
+		if err := agent.(Masker).Mask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		script, _ := ioutil.ReadFile(filepath.Join(root, "/etc/init.d/agent"))
+		if !strings.Contains(string(script), "agent is masked") {
+			t.Errorf("expected the init script replaced, got:\n%s", script)
+		}
+		if links, _ := filepath.Glob(filepath.Join(root, "/etc/rc?.d/*agent")); len(links) > 0 {
+			t.Errorf("expected the rc links removed, got %v", links)
+		}
+		if info := agent.Info(); !info.IsMasked || info.Status.EnabledState != "masked" {
+			t.Errorf("expected masked, got %v, %s", info.IsMasked, info.Status.EnabledState)
+		}
+		if err := agent.Enable(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected a masked service not to enable, got %v", err)
+		}
+
+		if err := agent.(Masker).Unmask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if script, _ := ioutil.ReadFile(filepath.Join(root, "/etc/init.d/agent")); string(script) != string(original) {
+			t.Errorf("expected the init script restored, got:\n%s", script)
+		}
+		if isEnabled, err := agent.IsEnabled(); err != nil || isEnabled {
+			t.Errorf("expected unmasking not to enable, got %v, %v", isEnabled, err)
+		}
+	})
+
+	t.Run("upstart", func(t *testing.T) {
+		opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitUpstart)}
+
+		agent, err := NewServiceFromSERVICE(agentSpec, opts...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.Install(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.Disable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := agent.(Masker).Mask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		override, _ := ioutil.ReadFile(filepath.Join(root, "/etc/init/agent.override"))
+		if !strings.Contains(string(override), "pre-start exec /bin/false") {
+			t.Errorf("expected the mask in the override, got:\n%s", override)
+		}
+		if info := agent.Info(); !info.IsMasked || info.Status.EnabledState != "masked" {
+			t.Errorf("expected masked, got %v, %s", info.IsMasked, info.Status.EnabledState)
+		}
+		if err := agent.Enable(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected a masked service not to enable, got %v", err)
+		}
+
+		// INFO: disabled before masking, it stays that way
+		if err := agent.(Masker).Unmask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if override, _ := ioutil.ReadFile(filepath.Join(root, "/etc/init/agent.override")); string(override) != "manual\n" {
+			t.Errorf("expected only manual left, got:\n%s", override)
+		}
+
+		// INFO: disabled while masked, the `manual` in the mask doesn't count
+		if err := agent.Enable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.(Masker).Mask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.Disable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := agent.(Masker).Unmask(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isEnabled, err := agent.IsEnabled(); err != nil || isEnabled {
+			t.Errorf("expected disabled after unmasking, got %v, %v", isEnabled, err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdUninstallReloads(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "systemkit-uninstall")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(configDir)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", configDir)
+
+	commands := []string{}
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", nil
+	})
+
+	echoSpec := spec.NewEmptySERVICE()
+	echoSpec.Name = "echo"
+	echoSpec.Executable = "/usr/bin/echo-server"
+
+	echo := newServiceFromSERVICE_SystemD(echoSpec, newOptions([]Option{WithExecutor(executor), WithScope(ScopeUser)}))
+	if err := echo.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commands = []string{}
+	if err := echo.Uninstall(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "systemd/user/echo.service")); !os.IsNotExist(err) {
+		t.Errorf("expected the unit removed, got %v", err)
+	}
+	if len(commands) <= 0 || commands[len(commands)-1] != "systemctl --user daemon-reload" {
+		t.Errorf("expected a daemon-reload once the unit is gone, got %v", commands)
+	}
+
+	// INFO: nothing to reload under `WithRoot()`
+	offline := newServiceFromSERVICE_SystemD(echoSpec, newOptions([]Option{WithExecutor(executor), WithRoot(configDir), WithScope(ScopeSystem)}))
+
+	commands = []string{}
+	if err := offline.Uninstall(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) > 0 {
+		t.Errorf("expected no commands offline, got %v", commands)
+	}
+}
+
+func TestSystemvReloadUnsupported(t *testing.T) {
+	output := "Usage: /etc/init.d/daemon {start|stop|restart|status}\n"
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		return output, errors.New("exit status 1")
+	})
+
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+
+	err := newServiceFromSERVICE_SystemV(daemonSpec, newOptions([]Option{WithExecutor(executor)})).Reload()
+	if !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest for a script without reload, got %v", err)
+	}
+
+	var operationError *OperationError
+	if !errors.As(err, &operationError) || operationError.Output != output {
+		t.Errorf("expected the OperationError kept, got %v", err)
+	}
+}
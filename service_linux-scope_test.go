@@ -0,0 +1,98 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+	"github.com/codemodify/systemkit-service/helpers"
+)
+
+func TestScopeForInitType(t *testing.T) {
+	for _, testCase := range []struct {
+		initType  spec.InitType
+		scheduled bool
+		expected  Scope
+	}{
+		{spec.InitSystemd, false, ScopeAuto},
+		{spec.InitSystemd, true, ScopeAuto},
+		{spec.InitUpstart, false, ScopeAuto},
+		{spec.InitUpstart, true, ScopeSystem},
+		{spec.InitSystemV, false, ScopeSystem},
+		{spec.InitSystemV, true, ScopeSystem},
+	} {
+		opts := []Option{}
+		if testCase.scheduled {
+			opts = append(opts, WithSchedule(Schedule{Every: time.Hour}))
+		}
+
+		if scope := newOptions(opts).forInitType(testCase.initType).scope; scope != testCase.expected {
+			t.Errorf("%s, scheduled %v: expected %s, got %s", testCase.initType, testCase.scheduled, testCase.expected, scope)
+		}
+	}
+
+	if scope := newOptions([]Option{WithScope(ScopeUser)}).forInitType(spec.InitSystemV).scope; scope != ScopeUser {
+		t.Errorf("expected an explicit scope kept, got %s", scope)
+	}
+}
+
+func TestScopePaths(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-scope")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Unsetenv("XDG_CONFIG_HOME")
+
+	scopedSpec := spec.NewEmptySERVICE()
+	scopedSpec.Name = "scoped"
+	scopedSpec.Executable = "/usr/bin/scoped"
+
+	homeDir := helpers.HomeDir("")
+	hourly := WithSchedule(Schedule{Every: time.Hour})
+
+	for _, testCase := range []struct {
+		name     string
+		opts     []Option
+		filePath string
+	}{
+		{"systemd system", []Option{WithInitType(spec.InitSystemd), WithScope(ScopeSystem)}, "/etc/systemd/system/scoped.service"},
+		{"systemd user", []Option{WithInitType(spec.InitSystemd), WithScope(ScopeUser)}, filepath.Join(homeDir, ".config/systemd/user/scoped.service")},
+		{"upstart system", []Option{WithInitType(spec.InitUpstart), WithScope(ScopeSystem)}, "/etc/init/scoped.conf"},
+		{"upstart user", []Option{WithInitType(spec.InitUpstart), WithScope(ScopeUser)}, filepath.Join(homeDir, ".config/upstart/scoped.conf")},
+		{"systemv auto", []Option{WithInitType(spec.InitSystemV)}, "/etc/init.d/scoped"},
+		{"cron on systemv auto", []Option{WithInitType(spec.InitSystemV), hourly}, "/etc/cron.d/scoped"},
+		{"cron on upstart auto", []Option{WithInitType(spec.InitUpstart), hourly}, "/etc/cron.d/scoped"},
+	} {
+		scoped, err := NewServiceFromSERVICE(scopedSpec, append(testCase.opts, WithRoot(root))...)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.name, err)
+			continue
+		}
+		if err := scoped.Install(); err != nil {
+			t.Errorf("%s: unexpected error: %v", testCase.name, err)
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(root, testCase.filePath)); err != nil {
+			t.Errorf("%s: expected %s, got %v", testCase.name, testCase.filePath, err)
+		}
+	}
+
+	for _, opts := range [][]Option{
+		{WithInitType(spec.InitSystemV), WithScope(ScopeUser)},
+		{WithInitType(spec.InitUpstart), WithScope(ScopeUser), hourly},
+	} {
+		if _, err := NewServiceFromSERVICE(scopedSpec, append(opts, WithRoot(root))...); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected ErrServiceUnsupportedRequest for user scope, got %v", err)
+		}
+	}
+}
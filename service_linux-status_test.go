@@ -0,0 +1,73 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestStateFromLSBExitCode(t *testing.T) {
+	for _, testCase := range []struct {
+		exitCode int
+		state    State
+		subState string
+	}{
+		{0, StateActive, "running"},
+		{1, StateFailed, "dead"},
+		{2, StateFailed, "dead"},
+		{3, StateInactive, "dead"},
+		{4, StateUnknown, ""},
+		{150, StateUnknown, ""},
+	} {
+		state, subState := stateFromLSBExitCode(testCase.exitCode)
+		if state != testCase.state || subState != testCase.subState {
+			t.Errorf("exit code %d: expected %s/%s, got %s/%s", testCase.exitCode, testCase.state, testCase.subState, state, subState)
+		}
+	}
+}
+
+func TestUpstartStatus(t *testing.T) {
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+
+	for _, testCase := range []struct {
+		output    string
+		err       error
+		state     State
+		subState  string
+		isRunning bool
+		pid       int
+		infoErr   error
+	}{
+		{"daemon start/running, process 1234\n", nil, StateActive, "running", true, 1234, nil},
+		{"daemon start/spawned, process 1234\n", nil, StateActivating, "spawned", false, 1234, nil},
+		{"daemon stop/waiting\n", nil, StateInactive, "waiting", false, -1, nil},
+		{"daemon stop/killed, process 1234\n", nil, StateDeactivating, "killed", false, 1234, nil},
+		{"initctl: Unknown job: daemon\n", errors.New("exit status 1"), StateUnknown, "", false, -1, ErrServiceDoesNotExist},
+	} {
+		var command []string
+		executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+			if command == nil {
+				command = append([]string{name}, args...)
+			}
+			return testCase.output, testCase.err
+		})
+
+		info := newServiceFromSERVICE_Upstart(daemonSpec, newOptions([]Option{WithExecutor(executor), WithScope(ScopeSystem)})).Info()
+
+		if len(command) != 3 || command[0] != "initctl" || command[1] != "status" || command[2] != "daemon" {
+			t.Errorf("%q: unexpected command: %v", testCase.output, command)
+		}
+		if info.Status.State != testCase.state || info.Status.SubState != testCase.subState || info.IsRunning != testCase.isRunning || info.PID != testCase.pid {
+			t.Errorf("%q: unexpected info: %s/%s, running %v, pid %d", testCase.output, info.Status.State, info.Status.SubState, info.IsRunning, info.PID)
+		}
+		if !errors.Is(info.Error, testCase.infoErr) {
+			t.Errorf("%q: expected %v, got %v", testCase.output, testCase.infoErr, info.Error)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	systemdBusName          = "org.freedesktop.systemd1"
+	systemdObjectPath       = dbus.ObjectPath("/org/freedesktop/systemd1")
+	systemdManagerInterface = "org.freedesktop.systemd1.Manager"
+	systemdUnitInterface    = "org.freedesktop.systemd1.Unit"
+	systemdServiceInterface = "org.freedesktop.systemd1.Service"
+	systemdTimerInterface   = "org.freedesktop.systemd1.Timer"
+)
+
+// systemdUnitSuffixes - unit types, a name that ends in none of these is a service
+var systemdUnitSuffixes = []string{".service", ".socket", ".timer", ".target", ".path", ".mount", ".automount", ".swap", ".slice", ".scope", ".device"}
+
+// dbusErrorClassifiers - D-Bus error names systemd replies with, for each failure kind
+var dbusErrorClassifiers = map[string]error{
+	"org.freedesktop.systemd1.NoSuchUnit":                         ErrServiceDoesNotExist,
+	"org.freedesktop.DBus.Error.FileNotFound":                     ErrServiceDoesNotExist,
+	"org.freedesktop.systemd1.JobTypeNotApplicable":               ErrServiceUnsupportedRequest,
+	"org.freedesktop.DBus.Error.AccessDenied":                     ErrServicePermissionDenied,
+	"org.freedesktop.DBus.Error.InteractiveAuthorizationRequired": ErrServicePermissionDenied,
+	"org.freedesktop.DBus.Error.ServiceUnknown":                   ErrInitSystemNotDetected,
+	"org.freedesktop.DBus.Error.NoServer":                         ErrInitSystemNotDetected,
+	"org.freedesktop.DBus.Error.Timeout":                          ErrServiceTimeout,
+	"org.freedesktop.DBus.Error.BadMessage":                       ErrServiceConfigError,
+}
+
+// systemdUnitFileChange - what `EnableUnitFiles` and `DisableUnitFiles` did, ex: symlink, /etc/..., /lib/...
+type systemdUnitFileChange struct {
+	Type        string
+	Filename    string
+	Destination string
+}
+
+// systemdTransientProperty - a unit property as `StartTransientUnit` takes it, ex: Description, ExecStart
+type systemdTransientProperty struct {
+	Name  string
+	Value dbus.Variant
+}
+
+// systemdExecCommand - an `ExecStart=` line, the arguments include the executable as `argv[0]`
+type systemdExecCommand struct {
+	Path          string
+	Args          []string
+	IgnoreFailure bool
+}
+
+// systemdJobPollInterval - how often `runJob()` asks whether a job is still queued, in case its `JobRemoved` got lost
+var systemdJobPollInterval = 2 * time.Second
+
+// systemdDBusManager - talks to `org.freedesktop.systemd1` over D-Bus, the services built with the same options share a connection
+type systemdDBusManager struct {
+	opts options
+}
+
+func (thisRef systemdDBusManager) daemonReload(ctx context.Context) error {
+	return thisRef.call(ctx, "daemon-reload", nil, "Reload")
+}
+
+func (thisRef systemdDBusManager) startUnit(ctx context.Context, name string) error {
+	return thisRef.runJob(ctx, "start", "StartUnit", systemdUnitName(name))
+}
+
+func (thisRef systemdDBusManager) stopUnit(ctx context.Context, name string) error {
+	return thisRef.runJob(ctx, "stop", "StopUnit", systemdUnitName(name))
+}
+
+func (thisRef systemdDBusManager) restartUnit(ctx context.Context, name string) error {
+	return thisRef.runJob(ctx, "restart", "RestartUnit", systemdUnitName(name))
+}
+
+func (thisRef systemdDBusManager) reloadUnit(ctx context.Context, name string) error {
+	return thisRef.runJob(ctx, "reload", "ReloadUnit", systemdUnitName(name))
+}
+
+func (thisRef systemdDBusManager) enableUnit(ctx context.Context, name string) error {
+	// INFO: nothing in the reply is worth reporting
+	var carriesInstallInfo bool
+	var changes []systemdUnitFileChange
+	return thisRef.call(ctx, "enable", []interface{}{&carriesInstallInfo, &changes}, "EnableUnitFiles", []string{systemdUnitName(name)}, false, false)
+}
+
+func (thisRef systemdDBusManager) disableUnit(ctx context.Context, name string) error {
+	var changes []systemdUnitFileChange
+	return thisRef.call(ctx, "disable", []interface{}{&changes}, "DisableUnitFiles", []string{systemdUnitName(name)}, false)
+}
+
+func (thisRef systemdDBusManager) maskUnit(ctx context.Context, name string) error {
+	var changes []systemdUnitFileChange
+	return thisRef.call(ctx, "mask", []interface{}{&changes}, "MaskUnitFiles", []string{systemdUnitName(name)}, false, false)
+}
+
+func (thisRef systemdDBusManager) unmaskUnit(ctx context.Context, name string) error {
+	var changes []systemdUnitFileChange
+	return thisRef.call(ctx, "unmask", []interface{}{&changes}, "UnmaskUnitFiles", []string{systemdUnitName(name)}, false)
+}
+
+func (thisRef systemdDBusManager) resetFailed(ctx context.Context) error {
+	return thisRef.call(ctx, "reset-failed", nil, "ResetFailed")
+}
+
+func (thisRef systemdDBusManager) unitFileState(ctx context.Context, name string) (string, error) {
+	var state string
+	err := thisRef.call(ctx, "is-enabled", []interface{}{&state}, "GetUnitFileState", systemdUnitName(name))
+	return state, err
+}
+
+func (thisRef systemdDBusManager) unitProperties(ctx context.Context, name string, names []string) (systemdProperties, error) {
+	conn, err := thisRef.connection(ctx)
+	if err != nil {
+		return nil, thisRef.operationError("show", "LoadUnit", err)
+	}
+
+	// 1. `LoadUnit` works for units that are not running, `GetUnit` does not
+	var unitPath dbus.ObjectPath
+	err = conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, systemdManagerInterface+".LoadUnit", 0, systemdUnitName(name)).Store(&unitPath)
+	if err != nil {
+		return nil, thisRef.operationError("show", "LoadUnit", err)
+	}
+
+	// 2. properties are spread over the generic unit and the unit type interfaces
+	values := map[string]dbus.Variant{}
+	for _, iface := range []string{systemdUnitInterface, systemdServiceInterface, systemdTimerInterface} {
+		ifaceValues := map[string]dbus.Variant{}
+		err = conn.Object(systemdBusName, unitPath).CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, iface).Store(&ifaceValues)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			// INFO: only services have the service interface, only timers the timer one
+			logging.Debugf("%s: RUN-DBUS-OUT: GetAll %s: %s", logTagSystemD, iface, err.Error())
+			continue
+		}
+
+		for key, value := range ifaceValues {
+			values[key] = value
+		}
+	}
+
+	// 3.
+	result := systemdProperties{}
+	for _, propertyName := range names {
+		if value, ok := values[propertyName]; ok {
+			result[propertyName] = systemdPropertyAsString(propertyName, value)
+		}
+	}
+
+	return result, nil
+}
+
+// startTransientUnit - `StartTransientUnit` with the properties `systemd-run` would set
+func (thisRef systemdDBusManager) startTransientUnit(ctx context.Context, serviceSpec spec.SERVICE) error {
+	properties := []systemdTransientProperty{
+		{"Description", dbus.MakeVariant(serviceSpec.Description)},
+		{"ExecStart", dbus.MakeVariant([]systemdExecCommand{{serviceSpec.Executable, append([]string{serviceSpec.Executable}, serviceSpec.Args...), false}})},
+		{"CollectMode", dbus.MakeVariant("inactive-or-failed")},
+	}
+	if len(serviceSpec.WorkingDirectory) > 0 {
+		properties = append(properties, systemdTransientProperty{"WorkingDirectory", dbus.MakeVariant(serviceSpec.WorkingDirectory)})
+	}
+	if len(serviceSpec.Environment) > 0 {
+		properties = append(properties, systemdTransientProperty{"Environment", dbus.MakeVariant(systemdEnvironment(serviceSpec.Environment))})
+	}
+	if len(serviceSpec.Credentials.User) > 0 {
+		properties = append(properties, systemdTransientProperty{"User", dbus.MakeVariant(serviceSpec.Credentials.User)})
+	}
+	if len(serviceSpec.Credentials.Group) > 0 {
+		properties = append(properties, systemdTransientProperty{"Group", dbus.MakeVariant(serviceSpec.Credentials.Group)})
+	}
+	if thisRef.opts.notify {
+		properties = append(properties, systemdTransientProperty{"Type", dbus.MakeVariant("notify")})
+		if thisRef.opts.watchdog > 0 {
+			properties = append(properties, systemdTransientProperty{"WatchdogUSec", dbus.MakeVariant(uint64(thisRef.opts.watchdog / time.Microsecond))})
+		}
+	}
+	if thisRef.opts.limits != nil {
+		properties = append(properties, systemdLimitProperties(*thisRef.opts.limits)...)
+	}
+
+	// INFO: no auxiliary units, that is for starting a scope together with its slice
+	auxiliaryUnits := []struct {
+		Name       string
+		Properties []systemdTransientProperty
+	}{}
+
+	return thisRef.runJob(ctx, "run", "StartTransientUnit", systemdUnitName(serviceSpec.Name), properties, auxiliaryUnits)
+}
+
+// runJob - systemd queues a job and replies right away, this waits for the job like `systemctl` does.
+// `extraArgs` go after the unit name and the job mode.
+func (thisRef systemdDBusManager) runJob(ctx context.Context, operation string, method string, unitName string, extraArgs ...interface{}) error {
+	conn, err := thisRef.connection(ctx)
+	if err != nil {
+		return thisRef.operationError(operation, method, err)
+	}
+
+	// 1. listen before asking, the job can be done before the reply arrives
+	jobsRemoved := make(chan *dbus.Signal, 256)
+	conn.Signal(jobsRemoved)
+	defer conn.RemoveSignal(jobsRemoved)
+
+	// 2.
+	logging.Debugf("%s: RUN-DBUS: %s %s", logTagSystemD, method, unitName)
+
+	var jobPath dbus.ObjectPath
+	args := append([]interface{}{unitName, "replace"}, extraArgs...)
+	err = conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, systemdManagerInterface+"."+method, 0, args...).Store(&jobPath)
+	if err != nil {
+		return thisRef.operationError(operation, method, err)
+	}
+
+	// 3. JobRemoved (u id, o job, s unit, s result)
+	poll := time.NewTicker(systemdJobPollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case signal, ok := <-jobsRemoved:
+			if !ok {
+				return thisRef.operationError(operation, method, errors.New("connection closed before the job finished"))
+			}
+
+			if signal.Name != systemdManagerInterface+".JobRemoved" || len(signal.Body) < 4 {
+				continue
+			}
+			if path, _ := signal.Body[1].(dbus.ObjectPath); path != jobPath {
+				continue
+			}
+
+			result, _ := signal.Body[3].(string)
+			logging.Debugf("%s: RUN-DBUS-OUT: %s %s: job %s", logTagSystemD, method, unitName, result)
+
+			if result == "done" || result == "skipped" {
+				return nil
+			}
+
+			return thisRef.operationError(operation, method, fmt.Errorf("job for %s finished with result %s", unitName, result))
+
+		case <-poll.C:
+			// INFO: a signal still on its way is read first, the job being gone says nothing about how it went
+			if len(jobsRemoved) > 0 || thisRef.isJobQueued(ctx, jobPath) {
+				continue
+			}
+
+			logging.Debugf("%s: RUN-DBUS-OUT: %s %s: job gone without a JobRemoved", logTagSystemD, method, unitName)
+			return thisRef.jobMissed(ctx, operation, method, unitName)
+		}
+	}
+}
+
+// isJobQueued - `GetJob` fails with `NoSuchJob` once the job is done, anything else counts as still queued
+func (thisRef systemdDBusManager) isJobQueued(ctx context.Context, jobPath dbus.ObjectPath) bool {
+	jobID, err := strconv.ParseUint(filepath.Base(string(jobPath)), 10, 32)
+	if err != nil {
+		return true
+	}
+
+	conn, err := thisRef.connection(ctx)
+	if err != nil {
+		return true
+	}
+
+	var path dbus.ObjectPath
+	err = conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, systemdManagerInterface+".GetJob", 0, uint32(jobID)).Store(&path)
+
+	return dbusErrorName(err) != "org.freedesktop.systemd1.NoSuchJob"
+}
+
+// jobMissed - the job is done and its result got lost, the unit's state is what is left to go by
+func (thisRef systemdDBusManager) jobMissed(ctx context.Context, operation string, method string, unitName string) error {
+	properties, err := thisRef.unitProperties(ctx, unitName, []string{"ActiveState"})
+	if err != nil {
+		return err
+	}
+
+	// INFO: a failed unit stays failed after a stop, until `reset-failed`
+	if properties["ActiveState"] == "failed" && operation != "stop" {
+		return thisRef.operationError(operation, method, fmt.Errorf("job for %s finished and the unit failed", unitName))
+	}
+
+	return nil
+}
+
+// call - a Manager method, `results` are pointers the reply is stored into
+func (thisRef systemdDBusManager) call(ctx context.Context, operation string, results []interface{}, method string, args ...interface{}) error {
+	conn, err := thisRef.connection(ctx)
+	if err != nil {
+		return thisRef.operationError(operation, method, err)
+	}
+
+	logging.Debugf("%s: RUN-DBUS: %s %v", logTagSystemD, method, args)
+
+	call := conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, systemdManagerInterface+"."+method, 0, args...)
+	if call.Err == nil && len(results) > 0 {
+		call.Err = call.Store(results...)
+	}
+
+	if call.Err != nil {
+		logging.Debugf("%s: RUN-DBUS-OUT: %s: %s", logTagSystemD, method, call.Err.Error())
+	}
+
+	return thisRef.operationError(operation, method, call.Err)
+}
+
+// connection - made on first use and again once it is closed, it listens for `JobRemoved` before any job is queued on it
+func (thisRef systemdDBusManager) connection(ctx context.Context) (*dbus.Conn, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	thisRef.opts.dbusConn.mutex.Lock()
+	defer thisRef.opts.dbusConn.mutex.Unlock()
+
+	if conn, ok := thisRef.opts.dbusConn.conn.(*dbus.Conn); ok && conn.Connected() {
+		return conn, nil
+	}
+
+	// 1. not tied to `ctx`, the connection outlives the call that made it
+	conn, err := thisRef.connect(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	// 2.
+	err = conn.AddMatchSignalContext(ctx, dbus.WithMatchObjectPath(systemdObjectPath), dbus.WithMatchInterface(systemdManagerInterface), dbus.WithMatchMember("JobRemoved"))
+	if err == nil {
+		err = conn.Object(systemdBusName, systemdObjectPath).CallWithContext(ctx, systemdManagerInterface+".Subscribe", 0).Err
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	thisRef.opts.dbusConn.conn = conn
+
+	return conn, nil
+}
+
+// connect - the bus from `WithSystemdDBus()`, otherwise the system bus or the session bus for user services
+func (thisRef systemdDBusManager) connect(ctx context.Context) (*dbus.Conn, error) {
+	if len(thisRef.opts.dbusAddress) > 0 {
+		return dbus.Connect(thisRef.opts.dbusAddress, dbus.WithContext(ctx))
+	}
+
+	if thisRef.opts.isUserScope() {
+		var conn *dbus.Conn
+		var err error
+		if address := userManagerBusAddress(); len(address) > 0 {
+			conn, err = dbus.Connect(address, dbus.WithContext(ctx))
+		} else {
+			conn, err = dbus.ConnectSessionBus(dbus.WithContext(ctx))
+		}
+		if err != nil && ctx.Err() == nil {
+			return nil, errUserManagerUnreachable(err)
+		}
+
+		return conn, err
+	}
+
+	return dbus.ConnectSystemBus(dbus.WithContext(ctx))
+}
+
+// operationError - same as for `systemctl`, D-Bus replies with error names instead of text to look for
+func (thisRef systemdDBusManager) operationError(operation string, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	output := err.Error()
+	kind := classifyOutput(output, err)
+
+	if name := dbusErrorName(err); len(name) > 0 {
+		if dbusKind, ok := dbusErrorClassifiers[name]; ok {
+			kind = dbusKind
+		}
+		output = name + ": " + output
+	}
+
+	return &OperationError{
+		Backend:   string(spec.InitSystemd),
+		Operation: operation,
+		Command:   []string{"dbus", systemdBusName, method},
+		ExitCode:  -1,
+		Output:    output,
+		Kind:      kind,
+		Err:       err,
+	}
+}
+
+// dbusErrorName - replies carry `dbus.Error`, the library's own failures `*dbus.Error`
+func dbusErrorName(err error) string {
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		return dbusErr.Name
+	}
+
+	var dbusErrPtr *dbus.Error
+	if errors.As(err, &dbusErrPtr) {
+		return dbusErrPtr.Name
+	}
+
+	return ""
+}
+
+// systemdLimitProperties - Limits the way D-Bus takes them, `CPUQuota=` is in microseconds of CPU time per second there
+func systemdLimitProperties(limits Limits) []systemdTransientProperty {
+	result := []systemdTransientProperty{}
+	if limits.MemoryMax > 0 {
+		result = append(result, systemdTransientProperty{"MemoryMax", dbus.MakeVariant(limits.MemoryMax)})
+	}
+	if limits.CPUQuota > 0 {
+		result = append(result, systemdTransientProperty{"CPUQuotaPerSecUSec", dbus.MakeVariant(uint64(limits.CPUQuota) * 10000)})
+	}
+	if limits.TasksMax > 0 {
+		result = append(result, systemdTransientProperty{"TasksMax", dbus.MakeVariant(limits.TasksMax)})
+	}
+	if limits.OpenFiles > 0 {
+		result = append(result,
+			systemdTransientProperty{"LimitNOFILE", dbus.MakeVariant(limits.OpenFiles)},
+			systemdTransientProperty{"LimitNOFILESoft", dbus.MakeVariant(limits.OpenFiles)},
+		)
+	}
+
+	return result
+}
+
+// systemdUnitName - `systemctl` adds `.service` to bare names, D-Bus wants the full name
+func systemdUnitName(name string) string {
+	suffix := filepath.Ext(name)
+	for _, unitSuffix := range systemdUnitSuffixes {
+		if suffix == unitSuffix {
+			return name
+		}
+	}
+
+	return name + ".service"
+}
+
+// systemdPropertyAsString - formats a property the way `systemctl show --timestamp=unix` does
+func systemdPropertyAsString(name string, value dbus.Variant) string {
+	switch typed := value.Value().(type) {
+	case string:
+		return typed
+	case dbus.ObjectPath:
+		return string(typed)
+	case []string:
+		return strings.Join(typed, " ")
+	case bool:
+		if typed {
+			return "yes"
+		}
+		return "no"
+	case uint64:
+		// INFO: timestamps are microseconds since the epoch, zero is "never"
+		if strings.HasSuffix(name, "Timestamp") || name == "LastTriggerUSec" || name == "NextElapseUSecRealtime" {
+			if typed == 0 {
+				return ""
+			}
+			return "@" + strconv.FormatUint(typed/1000000, 10)
+		}
+		return strconv.FormatUint(typed, 10)
+	case uint32:
+		return strconv.FormatUint(uint64(typed), 10)
+	case int32:
+		return strconv.FormatInt(int64(typed), 10)
+	case int64:
+		return strconv.FormatInt(typed, 10)
+	}
+
+	return fmt.Sprint(value.Value())
+}
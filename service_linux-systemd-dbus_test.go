@@ -0,0 +1,352 @@
+// +build linux
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+	"github.com/godbus/dbus/v5"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%DIR%</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startTestBus - a private `dbus-daemon`, the test is skipped where there is none
+func startTestBus(t *testing.T) (string, func()) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir, err := ioutil.TempDir("", "systemkit-dbus")
+	if err != nil {
+		t.Fatalf("can't create bus folder: %v", err)
+	}
+
+	configFile := filepath.Join(dir, "bus.conf")
+	ioutil.WriteFile(configFile, []byte(strings.Replace(testBusConfig, "%DIR%", dir, 1)), 0644)
+
+	daemon := exec.Command("dbus-daemon", "--config-file="+configFile, "--print-address", "--nofork", "--nopidfile")
+	stdout, _ := daemon.StdoutPipe()
+	if err := daemon.Start(); err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("can't start dbus-daemon: %v", err)
+	}
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		daemon.Process.Kill()
+		os.RemoveAll(dir)
+		t.Fatalf("dbus-daemon did not print its address: %v", err)
+	}
+
+	return strings.TrimSpace(address), func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+		os.RemoveAll(dir)
+	}
+}
+
+// mockSystemd - answers for `org.freedesktop.systemd1` with a single `nginx.service`
+type mockSystemd struct {
+	conn        *dbus.Conn
+	mutex       sync.Mutex
+	calls       []string
+	jobResult   string
+	dropSignals bool // the job is done but its `JobRemoved` never arrives
+}
+
+func (thisRef *mockSystemd) record(call string) {
+	thisRef.mutex.Lock()
+	defer thisRef.mutex.Unlock()
+	thisRef.calls = append(thisRef.calls, call)
+}
+
+func (thisRef *mockSystemd) setJobResult(jobResult string) {
+	thisRef.mutex.Lock()
+	defer thisRef.mutex.Unlock()
+	thisRef.jobResult = jobResult
+}
+
+func (thisRef *mockSystemd) setDropSignals(dropSignals bool) {
+	thisRef.mutex.Lock()
+	defer thisRef.mutex.Unlock()
+	thisRef.dropSignals = dropSignals
+}
+
+func (thisRef *mockSystemd) takeCalls() []string {
+	thisRef.mutex.Lock()
+	defer thisRef.mutex.Unlock()
+	result := thisRef.calls
+	thisRef.calls = nil
+	return result
+}
+
+func (thisRef *mockSystemd) unit(method string, name string) (dbus.ObjectPath, *dbus.Error) {
+	thisRef.record(method + " " + name)
+	if name != "nginx.service" {
+		return "", dbus.NewError("org.freedesktop.systemd1.NoSuchUnit", []interface{}{"Unit " + name + " not found."})
+	}
+
+	thisRef.mutex.Lock()
+	jobResult := thisRef.jobResult
+	dropSignals := thisRef.dropSignals
+	thisRef.mutex.Unlock()
+
+	jobPath := dbus.ObjectPath("/org/freedesktop/systemd1/job/42")
+	if !dropSignals {
+		go thisRef.conn.Emit(systemdObjectPath, systemdManagerInterface+".JobRemoved", uint32(42), jobPath, name, jobResult)
+	}
+
+	return jobPath, nil
+}
+
+func (thisRef *mockSystemd) GetJob(id uint32) (dbus.ObjectPath, *dbus.Error) {
+	thisRef.record(fmt.Sprintf("GetJob %d", id))
+	return "", dbus.NewError("org.freedesktop.systemd1.NoSuchJob", []interface{}{fmt.Sprintf("Job %d does not exist.", id)})
+}
+
+func (thisRef *mockSystemd) StartTransientUnit(name string, mode string, properties []systemdTransientProperty, aux []struct {
+	Name       string
+	Properties []systemdTransientProperty
+}) (dbus.ObjectPath, *dbus.Error) {
+	call := "StartTransientUnit " + name
+	for _, property := range properties {
+		if commands, ok := property.Value.Value().([][]interface{}); ok && property.Name == "ExecStart" {
+			call += " " + fmt.Sprint(commands[0][1])
+		}
+		if environment, ok := property.Value.Value().([]string); ok {
+			call += " " + strings.Join(environment, " ")
+		}
+	}
+	thisRef.record(call)
+
+	jobPath := dbus.ObjectPath("/org/freedesktop/systemd1/job/43")
+	go thisRef.conn.Emit(systemdObjectPath, systemdManagerInterface+".JobRemoved", uint32(43), jobPath, name, "done")
+
+	return jobPath, nil
+}
+
+func (thisRef *mockSystemd) Reload() *dbus.Error {
+	thisRef.record("Reload")
+	return nil
+}
+
+func (thisRef *mockSystemd) Subscribe() *dbus.Error {
+	return nil
+}
+
+func (thisRef *mockSystemd) ResetFailed() *dbus.Error {
+	thisRef.record("ResetFailed")
+	return nil
+}
+
+func (thisRef *mockSystemd) StartUnit(name string, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return thisRef.unit("StartUnit", name)
+}
+
+func (thisRef *mockSystemd) StopUnit(name string, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return thisRef.unit("StopUnit", name)
+}
+
+func (thisRef *mockSystemd) RestartUnit(name string, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return thisRef.unit("RestartUnit", name)
+}
+
+func (thisRef *mockSystemd) ReloadUnit(name string, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return thisRef.unit("ReloadUnit", name)
+}
+
+func (thisRef *mockSystemd) EnableUnitFiles(files []string, runtime bool, force bool) (bool, []systemdUnitFileChange, *dbus.Error) {
+	thisRef.record("EnableUnitFiles " + strings.Join(files, " "))
+	return true, []systemdUnitFileChange{{"symlink", "/etc/systemd/system/multi-user.target.wants/nginx.service", "/lib/systemd/system/nginx.service"}}, nil
+}
+
+func (thisRef *mockSystemd) DisableUnitFiles(files []string, runtime bool) ([]systemdUnitFileChange, *dbus.Error) {
+	thisRef.record("DisableUnitFiles " + strings.Join(files, " "))
+	return []systemdUnitFileChange{}, nil
+}
+
+func (thisRef *mockSystemd) GetUnitFileState(name string) (string, *dbus.Error) {
+	thisRef.record("GetUnitFileState " + name)
+	return "enabled", nil
+}
+
+func (thisRef *mockSystemd) LoadUnit(name string) (dbus.ObjectPath, *dbus.Error) {
+	thisRef.record("LoadUnit " + name)
+	return "/org/freedesktop/systemd1/unit/nginx_2eservice", nil
+}
+
+// mockNginxUnit - `org.freedesktop.DBus.Properties` of the unit
+type mockNginxUnit struct{}
+
+func (thisRef mockNginxUnit) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	switch iface {
+	case systemdUnitInterface:
+		return map[string]dbus.Variant{
+			"LoadState":            dbus.MakeVariant("loaded"),
+			"ActiveState":          dbus.MakeVariant("active"),
+			"SubState":             dbus.MakeVariant("running"),
+			"UnitFileState":        dbus.MakeVariant("enabled"),
+			"StateChangeTimestamp": dbus.MakeVariant(uint64(1611655200123456)),
+			"FragmentPath":         dbus.MakeVariant("/lib/systemd/system/nginx.service"),
+		}, nil
+	case systemdServiceInterface:
+		return map[string]dbus.Variant{
+			"MainPID":        dbus.MakeVariant(uint32(812)),
+			"ExecMainCode":   dbus.MakeVariant(int32(0)),
+			"ExecMainStatus": dbus.MakeVariant(int32(0)),
+			"NRestarts":      dbus.MakeVariant(uint32(2)),
+		}, nil
+	}
+
+	return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", nil)
+}
+
+func startMockSystemd(t *testing.T, address string) *mockSystemd {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("can't connect to the test bus: %v", err)
+	}
+
+	mock := &mockSystemd{conn: conn, jobResult: "done"}
+	conn.Export(mock, systemdObjectPath, systemdManagerInterface)
+	conn.Export(mockNginxUnit{}, "/org/freedesktop/systemd1/unit/nginx_2eservice", "org.freedesktop.DBus.Properties")
+
+	reply, err := conn.RequestName(systemdBusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("can't own %s: %v", systemdBusName, err)
+	}
+
+	return mock
+}
+
+func TestSystemdDBusManager(t *testing.T) {
+	address, stopBus := startTestBus(t)
+	defer stopBus()
+
+	mock := startMockSystemd(t, address)
+	defer mock.conn.Close()
+
+	opts := newOptions([]Option{WithSystemdDBus(address), WithScope(ScopeSystem)})
+	nginx := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "nginx"}, opts)
+	missing := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "missing"}, opts)
+
+	expectCalls := func(t *testing.T, expected ...string) {
+		if got := mock.takeCalls(); strings.Join(got, "; ") != strings.Join(expected, "; ") {
+			t.Errorf("expected calls %v, got %v", expected, got)
+		}
+	}
+
+	t.Run("start", func(t *testing.T) {
+		if err := nginx.Start(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectCalls(t, "Reload", "StartUnit nginx.service")
+	})
+
+	t.Run("stop a unit that does not exist", func(t *testing.T) {
+		err := missing.Stop()
+		if !errors.Is(err, ErrServiceDoesNotExist) {
+			t.Fatalf("expected ErrServiceDoesNotExist, got %v", err)
+		}
+		expectCalls(t, "StopUnit missing.service")
+	})
+
+	t.Run("failed job", func(t *testing.T) {
+		mock.setJobResult("failed")
+		defer mock.setJobResult("done")
+
+		err := nginx.Restart()
+		if err == nil || !strings.Contains(err.Error(), "failed") {
+			t.Fatalf("expected the job result in the error, got %v", err)
+		}
+		expectCalls(t, "Reload", "RestartUnit nginx.service")
+	})
+
+	t.Run("lost JobRemoved", func(t *testing.T) {
+		mock.setDropSignals(true)
+		defer mock.setDropSignals(false)
+
+		defer func(interval time.Duration) { systemdJobPollInterval = interval }(systemdJobPollInterval)
+		systemdJobPollInterval = 10 * time.Millisecond
+
+		if err := nginx.Stop(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectCalls(t, "StopUnit nginx.service", "GetJob 42", "LoadUnit nginx.service")
+	})
+
+	t.Run("one connection", func(t *testing.T) {
+		conn := opts.dbusConn.conn
+		if conn == nil {
+			t.Fatalf("expected a connection kept")
+		}
+
+		nginx.IsEnabled()
+		missing.Stop()
+		if opts.dbusConn.conn != conn {
+			t.Errorf("expected the connection reused")
+		}
+		mock.takeCalls()
+	})
+
+	t.Run("enable", func(t *testing.T) {
+		if err := nginx.Enable(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectCalls(t, "Reload", "EnableUnitFiles nginx.service")
+	})
+
+	t.Run("is enabled", func(t *testing.T) {
+		isEnabled, err := nginx.IsEnabled()
+		if err != nil || !isEnabled {
+			t.Fatalf("expected enabled, got %v, %v", isEnabled, err)
+		}
+		expectCalls(t, "GetUnitFileState nginx.service")
+	})
+
+	t.Run("run transient", func(t *testing.T) {
+		migrate := spec.SERVICE{Name: "migrate", Executable: "/usr/bin/migrate", Args: []string{"up"}, Environment: map[string]string{"DB": "main"}}
+		if _, err := runTransient_SystemD(context.Background(), migrate, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectCalls(t, "StartTransientUnit migrate.service [/usr/bin/migrate up] DB=main")
+	})
+
+	t.Run("info", func(t *testing.T) {
+		info := nginx.InfoContext(context.Background())
+		if info.Error != nil {
+			t.Fatalf("unexpected error: %v", info.Error)
+		}
+		if !info.IsRunning || info.PID != 812 || info.Status.RestartCount != 2 || info.FilePath != "/lib/systemd/system/nginx.service" {
+			t.Errorf("unexpected info: running %v, pid %d, restarts %d, path %s", info.IsRunning, info.PID, info.Status.RestartCount, info.FilePath)
+		}
+		if info.Status.Since == nil || info.Status.Since.Unix() != 1611655200 {
+			t.Errorf("unexpected since: %v", info.Status.Since)
+		}
+		expectCalls(t, "LoadUnit nginx.service")
+	})
+}
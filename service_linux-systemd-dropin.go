@@ -0,0 +1,135 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	logging "github.com/codemodify/systemkit-logging"
+)
+
+func (thisRef systemdService) AddDropIn(name string, content string) error {
+	return thisRef.AddDropInContext(context.Background(), name, content)
+}
+
+func (thisRef systemdService) AddDropInContext(ctx context.Context, name string, content string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	fileName, err := systemdDropInFileName(name)
+	if err != nil {
+		return err
+	}
+
+	// 1.
+	logging.Debugf("making sure folder exists: %s", thisRef.dropInDir())
+	os.MkdirAll(thisRef.dropInDir(), os.ModePerm)
+
+	// 2.
+	logging.Debugf("writing drop-in to: %s", filepath.Join(thisRef.dropInDir(), fileName))
+	err = ioutil.WriteFile(filepath.Join(thisRef.dropInDir(), fileName), []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	// 3.
+	return thisRef.reloadAfterDropInChange(ctx)
+}
+
+func (thisRef systemdService) RemoveDropIn(name string) error {
+	return thisRef.RemoveDropInContext(context.Background(), name)
+}
+
+func (thisRef systemdService) RemoveDropInContext(ctx context.Context, name string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	fileName, err := systemdDropInFileName(name)
+	if err != nil {
+		return err
+	}
+
+	// 1.
+	logging.Debugf("remove drop-in: %s", filepath.Join(thisRef.dropInDir(), fileName))
+	err = os.Remove(filepath.Join(thisRef.dropInDir(), fileName))
+	if os.IsNotExist(err) {
+		return ErrServiceDoesNotExist
+	} else if err != nil {
+		return err
+	}
+
+	// 2. fails if there are other files left, that is fine
+	os.Remove(thisRef.dropInDir())
+
+	// 3.
+	return thisRef.reloadAfterDropInChange(ctx)
+}
+
+func (thisRef systemdService) ListDropIns() ([]DropIn, error) {
+	return thisRef.ListDropInsContext(context.Background())
+}
+
+// ListDropInsContext - the drop-ins in the folder `AddDropIn()` writes to, `Info().DropIns` has all the ones systemd applied
+func (thisRef systemdService) ListDropInsContext(ctx context.Context) ([]DropIn, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	matches, err := filepath.Glob(filepath.Join(thisRef.dropInDir(), "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+
+	result := []DropIn{}
+	for _, match := range matches {
+		content, err := ioutil.ReadFile(match)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, DropIn{
+			Name:     filepath.Base(match),
+			FilePath: match,
+			Content:  string(content),
+		})
+	}
+
+	return result, nil
+}
+
+// dropInDir - ex: `/etc/systemd/system/<name>.service.d`, it applies to vendor units in `/usr/lib/systemd/system` too
+func (thisRef systemdService) dropInDir() string {
+	return thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), thisRef.serviceUnitName()+".d"))
+}
+
+func (thisRef systemdService) reloadAfterDropInChange(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return nil
+	}
+
+	logging.Debugf("reloading daemon")
+	return thisRef.manager().daemonReload(ctx)
+}
+
+// systemdDropInFileName - systemd only reads `*.conf` files, `name` can't leave the drop-in folder
+func systemdDropInFileName(name string) (string, error) {
+	if len(name) <= 0 || strings.ContainsRune(name, os.PathSeparator) || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: invalid drop-in name %q", ErrServiceConfigError, name)
+	}
+
+	if !strings.HasSuffix(name, ".conf") {
+		name += ".conf"
+	}
+
+	return name, nil
+}
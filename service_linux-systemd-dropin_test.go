@@ -0,0 +1,81 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdDropIns(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-dropin")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	nginx := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "nginx"}, newOptions([]Option{WithRoot(root), WithScope(ScopeSystem)})).(DropInManager)
+
+	if err := nginx.AddDropIn("10-environment", "[Service]\nEnvironment=A=1\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := nginx.AddDropIn("../escape", ""); !errors.Is(err, ErrServiceConfigError) {
+		t.Errorf("expected ErrServiceConfigError for a name with a path, got %v", err)
+	}
+
+	dropIns, err := nginx.ListDropIns()
+	if err != nil || len(dropIns) != 1 {
+		t.Fatalf("expected a single drop-in, got %v, %v", dropIns, err)
+	}
+	if dropIns[0].Name != "10-environment.conf" || dropIns[0].FilePath != filepath.Join(root, "/etc/systemd/system/nginx.service.d/10-environment.conf") {
+		t.Errorf("unexpected drop-in: %+v", dropIns[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if dropIns, err := nginx.ListDropInsContext(ctx); err != nil || len(dropIns) != 1 {
+		t.Errorf("expected a single drop-in, got %v, %v", dropIns, err)
+	}
+	cancel()
+	if _, err := nginx.ListDropInsContext(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if err := nginx.RemoveDropIn("10-environment.conf"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := nginx.RemoveDropIn("10-environment.conf"); !errors.Is(err, ErrServiceDoesNotExist) {
+		t.Errorf("expected ErrServiceDoesNotExist, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "/etc/systemd/system/nginx.service.d")); !os.IsNotExist(err) {
+		t.Errorf("expected the empty drop-in folder to be removed, got %v", err)
+	}
+}
+
+func TestSystemdDropInsAcceptSocket(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-dropin")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	// INFO: each connection runs an `echo@<connection>.service` instance, the drop-in goes with their template
+	echo := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "echo"}, newOptions([]Option{WithRoot(root), WithScope(ScopeSystem), WithSocket(Socket{ListenStream: []string{"7"}, Accept: true})})).(DropInManager)
+
+	if err := echo.AddDropIn("10-environment", "[Service]\nEnvironment=A=1\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dropIns, err := echo.ListDropIns()
+	if err != nil || len(dropIns) != 1 {
+		t.Fatalf("expected a single drop-in, got %v, %v", dropIns, err)
+	}
+	if dropIns[0].FilePath != filepath.Join(root, "/etc/systemd/system/echo@.service.d/10-environment.conf") {
+		t.Errorf("unexpected drop-in: %+v", dropIns[0])
+	}
+}
@@ -0,0 +1,177 @@
+// +build linux
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// systemdJournalMaxLine - the longest journal entry `FollowLogs()` reads, longer ones end the stream
+const systemdJournalMaxLine = 4 * 1024 * 1024
+
+// systemdJournalEntry - the fields of a `journalctl --output json` line `Logs()` uses
+type systemdJournalEntry struct {
+	RealtimeTimestamp string      `json:"__REALTIME_TIMESTAMP"` // microseconds since the epoch
+	Message           interface{} `json:"MESSAGE"`              // a string, or an array of bytes if it is not valid UTF-8
+}
+
+func (thisRef systemdService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.LogsContext(context.Background(), since, limit)
+}
+
+// LogsContext - reads the journal, `journalctl` needs no running systemd so this works `WithRoot()` too
+func (thisRef systemdService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	// 1.
+	args := thisRef.journalctlArgs()
+	if !since.IsZero() {
+		args = append(args, "--since", "@"+strconv.FormatInt(since.Unix(), 10))
+	}
+	if limit > 0 {
+		args = append(args, "--lines", strconv.Itoa(limit))
+	}
+
+	// 2.
+	logging.Debugf("%s: RUN-JOURNALCTL: journalctl %s", logTagSystemD, strings.Join(args, " "))
+
+	output, err := thisRef.opts.executor.Exec(ctx, "journalctl", args...)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, newOperationError(string(spec.InitSystemd), "logs", append([]string{"journalctl"}, args...), output, err)
+	}
+
+	// 3.
+	result := []LogLine{}
+	for _, line := range strings.Split(output, "\n") {
+		if logLine, ok := parseJournalctlJSONLine(line); ok {
+			result = append(result, logLine)
+		}
+	}
+
+	return result, nil
+}
+
+// FollowLogs - `journalctl --follow`, needs an executor that can stream, the default one can
+func (thisRef systemdService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	streamingExecutor, ok := thisRef.opts.executor.(StreamingExecutor)
+	if !ok {
+		return nil, fmt.Errorf("%w: following logs needs a StreamingExecutor", ErrServiceUnsupportedRequest)
+	}
+
+	// 1. only what is printed from now on
+	args := append(thisRef.journalctlArgs(), "--follow", "--lines", "0")
+
+	// 2.
+	logging.Debugf("%s: RUN-JOURNALCTL: journalctl %s", logTagSystemD, strings.Join(args, " "))
+
+	stdout, err := streamingExecutor.ExecStream(ctx, "journalctl", args...)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, newOperationError(string(spec.InitSystemd), "logs", append([]string{"journalctl"}, args...), "", err)
+	}
+
+	// 3.
+	result := make(chan LogLine, 64)
+
+	go func() {
+		defer close(result)
+		defer stdout.Close()
+
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), systemdJournalMaxLine)
+
+		for scanner.Scan() {
+			logLine, ok := parseJournalctlJSONLine(scanner.Text())
+			if !ok {
+				continue
+			}
+
+			select {
+			case result <- logLine:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// 4. `journalctl` is killed once `ctx` is done, that is not a failure
+		if ctx.Err() != nil || scanner.Err() == nil {
+			return
+		}
+
+		select {
+		case result <- LogLine{Source: "journal", Error: newOperationError(string(spec.InitSystemd), "logs", append([]string{"journalctl"}, args...), "", scanner.Err())}:
+		case <-ctx.Done():
+		}
+	}()
+
+	return result, nil
+}
+
+// journalctlArgs - the arguments `Logs()` and `FollowLogs()` have in common
+func (thisRef systemdService) journalctlArgs() []string {
+	args := []string{"--unit", thisRef.logUnit(), "--no-pager", "--output", "json"}
+	if thisRef.opts.isUserScope() {
+		args = append([]string{"--user"}, args...)
+	}
+	if thisRef.opts.isOffline() {
+		args = append(args, "--root", thisRef.opts.root)
+	}
+
+	return args
+}
+
+// logUnit - connections of `Accept=yes` sockets each run in their own instance
+func (thisRef systemdService) logUnit() string {
+	if thisRef.opts.socket != nil && thisRef.opts.socket.Accept {
+		return thisRef.serviceSpec.Name + "@*.service"
+	}
+
+	return systemdUnitName(thisRef.serviceSpec.Name)
+}
+
+// parseJournalctlJSONLine - one JSON object per line, anything else, ex: hints `journalctl` prints on stderr, is skipped
+func parseJournalctlJSONLine(line string) (LogLine, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return LogLine{}, false
+	}
+
+	entry := systemdJournalEntry{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return LogLine{}, false
+	}
+
+	result := LogLine{Source: "journal"}
+
+	if microseconds, err := strconv.ParseInt(entry.RealtimeTimestamp, 10, 64); err == nil {
+		entryTime := time.Unix(0, microseconds*int64(time.Microsecond))
+		result.Time = &entryTime
+	}
+
+	switch message := entry.Message.(type) {
+	case string:
+		result.Text = message
+	case []interface{}:
+		bytes := []byte{}
+		for _, value := range message {
+			if number, ok := value.(float64); ok {
+				bytes = append(bytes, byte(number))
+			}
+		}
+		result.Text = strings.TrimRight(string(bytes), "\n")
+	}
+
+	return result, true
+}
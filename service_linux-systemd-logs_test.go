@@ -0,0 +1,152 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdLogs(t *testing.T) {
+	var command string
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		command = name + " " + strings.Join(args, " ")
+		return "Hint: You are currently not seeing messages from other users and the system.\n" +
+			`{"__REALTIME_TIMESTAMP":"1611655200123456","MESSAGE":"listening on :80","_PID":"812"}` + "\n" +
+			`{"__REALTIME_TIMESTAMP":"1611655201000000","MESSAGE":[98,105,110,10]}` + "\n", nil
+	})
+
+	nginx := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "nginx"}, newOptions([]Option{WithExecutor(executor), WithScope(ScopeSystem)})).(LogReader)
+
+	lines, err := nginx.Logs(time.Unix(1611655000, 0), 50)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if command != "journalctl --unit nginx.service --no-pager --output json --since @1611655000 --lines 50" {
+		t.Errorf("unexpected command: %s", command)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", lines)
+	}
+	if lines[0].Text != "listening on :80" || lines[0].Source != "journal" || lines[0].Time == nil || lines[0].Time.Unix() != 1611655200 {
+		t.Errorf("unexpected line: %+v", lines[0])
+	}
+	if lines[1].Text != "bin" {
+		t.Errorf("expected the binary message decoded, got %q", lines[1].Text)
+	}
+}
+
+// fakeJournal - a StreamingExecutor whose `journalctl --follow` prints what the test writes to `stdout`
+type fakeJournal struct {
+	command string
+	stdout  *io.PipeWriter
+	closed  chan struct{}
+}
+
+func (thisRef *fakeJournal) Exec(ctx context.Context, name string, args ...string) (string, error) {
+	return "", nil
+}
+
+func (thisRef *fakeJournal) ExecStream(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	thisRef.command = name + " " + strings.Join(args, " ")
+	thisRef.closed = make(chan struct{})
+
+	reader, writer := io.Pipe()
+	thisRef.stdout = writer
+
+	// INFO: the command is killed when `ctx` is done
+	go func() {
+		<-ctx.Done()
+		writer.CloseWithError(errors.New("signal: killed"))
+	}()
+
+	return fakeJournalReader{reader, thisRef.closed}, nil
+}
+
+type fakeJournalReader struct {
+	*io.PipeReader
+	closed chan struct{}
+}
+
+func (thisRef fakeJournalReader) Close() error {
+	close(thisRef.closed)
+	return thisRef.PipeReader.Close()
+}
+
+func TestSystemdFollowLogs(t *testing.T) {
+	journal := &fakeJournal{}
+	nginx := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "nginx"}, newOptions([]Option{WithExecutor(journal), WithScope(ScopeSystem)})).(LogReader)
+
+	next := func(lines <-chan LogLine) (LogLine, bool) {
+		select {
+		case line, ok := <-lines:
+			return line, ok
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no line and the channel is still open")
+		}
+		return LogLine{}, false
+	}
+
+	// 1. lines come as `journalctl` prints them, until `ctx` is done
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, err := nginx.FollowLogs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if journal.command != "journalctl --unit nginx.service --no-pager --output json --follow --lines 0" {
+		t.Errorf("unexpected command: %s", journal.command)
+	}
+
+	go io.WriteString(journal.stdout, `{"__REALTIME_TIMESTAMP":"1611655200123456","MESSAGE":"listening on :80"}`+"\n"+
+		"-- Journal begins at Mon 2021-01-25 --\n"+
+		`{"__REALTIME_TIMESTAMP":"1611655201000000","MESSAGE":"GET /"}`+"\n")
+
+	for _, expected := range []string{"listening on :80", "GET /"} {
+		if line, ok := next(lines); !ok || line.Text != expected || line.Time == nil || line.Error != nil {
+			t.Errorf("expected %q, got %+v, %v", expected, line, ok)
+		}
+	}
+
+	cancel()
+	if line, ok := next(lines); ok {
+		t.Errorf("expected the channel closed once ctx is done, got %+v", line)
+	}
+	select {
+	case <-journal.closed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected journalctl stopped")
+	}
+
+	// 2. `journalctl` failing ends the stream with its error
+	lines, err = nginx.FollowLogs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go func() {
+		io.WriteString(journal.stdout, `{"__REALTIME_TIMESTAMP":"1611655200123456","MESSAGE":"listening on :80"}`+"\n")
+		journal.stdout.CloseWithError(errors.New("exit status 1"))
+	}()
+
+	if line, ok := next(lines); !ok || line.Text != "listening on :80" {
+		t.Errorf("expected the line printed before the failure, got %+v, %v", line, ok)
+	}
+
+	line, ok := next(lines)
+	var operationError *OperationError
+	if !ok || !errors.As(line.Error, &operationError) || operationError.Operation != "logs" || operationError.Err.Error() != "exit status 1" {
+		t.Fatalf("expected the journalctl failure, got %+v, %v", line, ok)
+	}
+
+	if line, ok := next(lines); ok {
+		t.Errorf("expected the channel closed after the failure, got %+v", line)
+	}
+}
@@ -0,0 +1,158 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	logging "github.com/codemodify/systemkit-logging"
+)
+
+func (thisRef systemdService) Mask() error {
+	return thisRef.MaskContext(context.Background())
+}
+
+// MaskContext - `systemctl mask`, which refuses units that have their file where it would put the link, ex: the ones
+// installed here, those are masked from `<unit dir>.control` which systemd reads first. Sockets and timers are masked too.
+func (thisRef systemdService) MaskContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	maskedOnDisk := false
+
+	for _, unit := range thisRef.maskUnits() {
+		// 1. installed here, or nothing can be asked from systemd
+		if maskLink, ok := thisRef.maskLinkPath(unit); ok {
+			logging.Debugf("%s: masking %s with: %s", logTagSystemD, unit, maskLink)
+
+			os.MkdirAll(filepath.Dir(maskLink), os.ModePerm)
+			if _, err := os.Lstat(maskLink); err == nil {
+				continue
+			}
+
+			err := os.Symlink("/dev/null", maskLink)
+			if err != nil {
+				return err
+			}
+
+			maskedOnDisk = true
+			continue
+		}
+
+		// 2.
+		logging.Debugf("%s: masking %s with systemd", logTagSystemD, unit)
+		err := thisRef.manager().maskUnit(ctx, unit)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. `systemctl mask` reloads by itself, D-Bus and links made here need it
+	if thisRef.opts.isOffline() || (!maskedOnDisk && !thisRef.opts.systemdDBus) {
+		return nil
+	}
+
+	return thisRef.manager().daemonReload(ctx)
+}
+
+func (thisRef systemdService) Unmask() error {
+	return thisRef.UnmaskContext(context.Background())
+}
+
+// UnmaskContext - removes the links `MaskContext()` made, whichever way, the unit stays disabled if it was
+func (thisRef systemdService) UnmaskContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, unit := range thisRef.maskUnits() {
+		// 1.
+		for _, dir := range []string{thisRef.unitDir() + ".control", thisRef.unitDir()} {
+			maskLink := thisRef.opts.rooted(filepath.Join(dir, unit))
+			if target, err := os.Readlink(maskLink); err != nil || target != "/dev/null" {
+				continue
+			}
+
+			logging.Debugf("%s: unmasking %s, removing: %s", logTagSystemD, unit, maskLink)
+			err := os.Remove(maskLink)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		if thisRef.opts.isOffline() {
+			continue
+		}
+
+		// 2. masked some other way, ex: `systemctl mask --runtime`
+		logging.Debugf("%s: unmasking %s with systemd", logTagSystemD, unit)
+		err := thisRef.manager().unmaskUnit(ctx, unit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if thisRef.opts.isOffline() {
+		return nil
+	}
+
+	return thisRef.manager().daemonReload(ctx)
+}
+
+// removeControlMasks - removes the masks `MaskContext()` made in `<unit dir>.control`, they belong to the unit files here
+func (thisRef systemdService) removeControlMasks() error {
+	for _, unit := range thisRef.maskUnits() {
+		maskLink := thisRef.opts.rooted(filepath.Join(thisRef.unitDir()+".control", unit))
+		if target, err := os.Readlink(maskLink); err != nil || target != "/dev/null" {
+			continue
+		}
+
+		logging.Debugf("%s: removing mask: %s", logTagSystemD, maskLink)
+		err := os.Remove(maskLink)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isMasked - from the files on disk, systemd itself reports `masked` as the unit file state
+func (thisRef systemdService) isMasked() bool {
+	return isSystemdUnitMasked(thisRef.maskUnits()[0], thisRef.opts)
+}
+
+// maskUnits - the service and whatever starts it, ex: its socket or timer
+func (thisRef systemdService) maskUnits() []string {
+	result := []string{filepath.Base(thisRef.filePath())}
+	if len(thisRef.templateName) > 0 {
+		result = []string{thisRef.serviceSpec.Name + ".service"}
+	}
+
+	if thisRef.opts.socket != nil || thisRef.opts.schedule != nil {
+		result = append(result, filepath.Base(thisRef.enableFilePath()))
+	}
+
+	return result
+}
+
+// maskLinkPath - where the mask for `unit` has to be made by hand, false if `systemctl mask` can do it
+func (thisRef systemdService) maskLinkPath(unit string) (string, bool) {
+	unitFile := thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), unit))
+
+	// INFO: a link to `/dev/null` is a mask already, anything else is in the way
+	if _, err := os.Lstat(unitFile); err == nil {
+		if target, err := os.Readlink(unitFile); err != nil || target != "/dev/null" {
+			return thisRef.opts.rooted(filepath.Join(thisRef.unitDir()+".control", unit)), true
+		}
+	}
+
+	if thisRef.opts.isOffline() {
+		return unitFile, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,49 @@
+// +build linux
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdNotify(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-notify")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemd), WithNotify(90 * time.Second)}
+
+	daemonSpec := spec.NewEmptySERVICE()
+	daemonSpec.Name = "daemon"
+	daemonSpec.Executable = "/usr/bin/daemon"
+
+	fromSpec, err := NewServiceFromSERVICE(daemonSpec, opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fromTemplate, err := NewServiceFromPlatformTemplate("templated", "[Unit]\nDescription=templated\n\n[Service]\nType=simple\nExecStart=/usr/bin/templated\n", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, service := range map[string]Service{"daemon": fromSpec, "templated": fromTemplate} {
+		if err := service.Install(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		fileContent, _ := ioutil.ReadFile(filepath.Join(root, "/etc/systemd/system", name+".service"))
+		if !strings.Contains(string(fileContent), "[Service]\nType=notify\nWatchdogSec=90\n") || strings.Contains(string(fileContent), "Type=simple") {
+			t.Errorf("%s: expected a notify service, got:\n%s", name, fileContent)
+		}
+	}
+}
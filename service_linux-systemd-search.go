@@ -0,0 +1,192 @@
+// +build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codemodify/systemkit-service/helpers"
+)
+
+// errSystemdUnitMasked - the unit file is a link to `/dev/null`
+var errSystemdUnitMasked = fmt.Errorf("%w: the unit is masked", ErrServiceDoesNotExist)
+
+// systemdMaxSymlinks - how many links `findSystemdUnitFile()` follows before it gives up, ex: on a loop
+const systemdMaxSymlinks = 32
+
+// systemdUnitSearchPath - the folders systemd loads units from, highest precedence first, see `systemd.unit(5)`.
+// INFO: `/lib/systemd/system` is where Debian and Ubuntu without a merged `/usr` keep vendor units
+func systemdUnitSearchPath(opts options) []string {
+	if !opts.isUserScope() {
+		return []string{
+			"/etc/systemd/system.control",
+			"/run/systemd/system.control",
+			"/run/systemd/transient",
+			"/run/systemd/generator.early",
+			"/etc/systemd/system",
+			"/etc/systemd/system.attached",
+			"/run/systemd/system",
+			"/run/systemd/system.attached",
+			"/run/systemd/generator",
+			"/usr/local/lib/systemd/system",
+			"/lib/systemd/system",
+			"/usr/lib/systemd/system",
+			"/run/systemd/generator.late",
+		}
+	}
+
+	configHome := xdgDir("XDG_CONFIG_HOME", filepath.Join(helpers.HomeDir(""), ".config"))
+	dataHome := xdgDir("XDG_DATA_HOME", filepath.Join(helpers.HomeDir(""), ".local/share"))
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+
+	result := []string{filepath.Join(configHome, "systemd/user.control")}
+	if len(runtimeDir) > 0 {
+		result = append(result,
+			filepath.Join(runtimeDir, "systemd/user.control"),
+			filepath.Join(runtimeDir, "systemd/transient"),
+			filepath.Join(runtimeDir, "systemd/generator.early"),
+		)
+	}
+
+	result = append(result, filepath.Join(configHome, "systemd/user"))
+	for _, dir := range xdgDirs("XDG_CONFIG_DIRS", "/etc/xdg") {
+		result = append(result, filepath.Join(dir, "systemd/user"))
+	}
+	result = append(result, "/etc/systemd/user")
+
+	if len(runtimeDir) > 0 {
+		result = append(result, filepath.Join(runtimeDir, "systemd/user"))
+	}
+	result = append(result, "/run/systemd/user")
+	if len(runtimeDir) > 0 {
+		result = append(result, filepath.Join(runtimeDir, "systemd/generator"))
+	}
+
+	result = append(result, filepath.Join(dataHome, "systemd/user"))
+	for _, dir := range xdgDirs("XDG_DATA_DIRS", "/usr/local/share:/usr/share") {
+		result = append(result, filepath.Join(dir, "systemd/user"))
+	}
+	result = append(result, "/usr/local/lib/systemd/user", "/usr/lib/systemd/user")
+
+	if len(runtimeDir) > 0 {
+		result = append(result, filepath.Join(runtimeDir, "systemd/generator.late"))
+	}
+
+	return result
+}
+
+// systemdUserConfigDir - where user units are installed, `$XDG_CONFIG_HOME/systemd/user`
+func systemdUserConfigDir() string {
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", filepath.Join(helpers.HomeDir(""), ".config")), "systemd/user")
+}
+
+// findSystemdUnitFile - the file systemd would load `unitName` from, symlinks and aliases followed,
+// ex: `/etc/systemd/system/sshd.service -> /lib/systemd/system/ssh.service`
+// a masked unit is skipped for the file it masks, if there is one, `Unmask()` needs it
+func findSystemdUnitFile(unitName string, opts options) (string, error) {
+	isMasked := false
+
+	for _, dir := range systemdUnitSearchPath(opts) {
+		path := opts.rooted(filepath.Join(dir, unitName))
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+
+		result, err := resolveSystemdUnitFile(path, opts)
+		if err == errSystemdUnitMasked {
+			isMasked = true
+			continue
+		}
+
+		return result, err
+	}
+
+	if isMasked {
+		return "", errSystemdUnitMasked
+	}
+
+	return "", ErrServiceDoesNotExist
+}
+
+// isSystemdUnitMasked - whether the first file for `unitName` on the search path is a link to `/dev/null`
+func isSystemdUnitMasked(unitName string, opts options) bool {
+	path, ok := firstOnSystemdUnitSearchPath(unitName, opts)
+	if !ok {
+		return false
+	}
+
+	target, err := os.Readlink(path)
+	return err == nil && target == "/dev/null"
+}
+
+// firstOnSystemdUnitSearchPath - the file or link for `unitName` with the highest precedence, not followed
+func firstOnSystemdUnitSearchPath(unitName string, opts options) (string, bool) {
+	for _, dir := range systemdUnitSearchPath(opts) {
+		path := opts.rooted(filepath.Join(dir, unitName))
+
+		if _, err := os.Lstat(path); err == nil {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// resolveSystemdUnitFile - follows the links at `path` one at a time, absolute targets are inside `WithRoot()`
+func resolveSystemdUnitFile(path string, opts options) (string, error) {
+	for i := 0; i < systemdMaxSymlinks; i++ {
+		fileInfo, err := os.Lstat(path)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s is a broken link", ErrServiceDoesNotExist, path)
+		}
+
+		if fileInfo.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+
+		if target == "/dev/null" {
+			return "", errSystemdUnitMasked
+		}
+
+		if filepath.IsAbs(target) {
+			path = opts.rooted(target)
+		} else {
+			path = filepath.Join(filepath.Dir(path), target)
+		}
+	}
+
+	return "", fmt.Errorf("%w: too many links at %s", ErrServiceConfigError, path)
+}
+
+// xdgDir - the value of `$name`, `defaultValue` if it is not set or not absolute, as the spec says
+func xdgDir(name string, defaultValue string) string {
+	if value := os.Getenv(name); filepath.IsAbs(value) {
+		return value
+	}
+
+	return defaultValue
+}
+
+// xdgDirs - the absolute folders in the `:` separated list `$name`, `defaultValue` if there are none
+func xdgDirs(name string, defaultValue string) []string {
+	result := []string{}
+	for _, value := range strings.Split(os.Getenv(name), ":") {
+		if filepath.IsAbs(value) {
+			result = append(result, value)
+		}
+	}
+
+	if len(result) <= 0 {
+		result = strings.Split(defaultValue, ":")
+	}
+
+	return result
+}
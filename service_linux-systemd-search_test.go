@@ -0,0 +1,117 @@
+// +build linux
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdUnitSearchPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-search")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	writeUnit := func(path string, content string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, path)), os.ModePerm)
+		if err := ioutil.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatalf("can't write %s: %v", path, err)
+		}
+	}
+	linkUnit := func(path string, target string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, path)), os.ModePerm)
+		if err := os.Symlink(target, filepath.Join(root, path)); err != nil {
+			t.Fatalf("can't link %s: %v", path, err)
+		}
+	}
+
+	// vendor unit on Debian, an alias to it, one overridden in `/etc` and a masked one
+	writeUnit("/lib/systemd/system/ssh.service", "[Service]\nExecStart=/usr/sbin/sshd -D\n")
+	linkUnit("/etc/systemd/system/sshd.service", "/lib/systemd/system/ssh.service")
+	writeUnit("/usr/lib/systemd/system/cron.service", "[Service]\nExecStart=/usr/sbin/cron -f\n")
+	writeUnit("/etc/systemd/system/cron.service", "[Service]\nExecStart=/usr/sbin/cron -f -L 15\n")
+	writeUnit("/run/systemd/system/runtime.service", "[Service]\nExecStart=/bin/runtime\n")
+	linkUnit("/etc/systemd/system/telnet.service", "/dev/null")
+
+	opts := []Option{WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemd)}
+
+	for name, expected := range map[string]string{
+		"ssh":     "/lib/systemd/system/ssh.service",
+		"sshd":    "/lib/systemd/system/ssh.service",
+		"cron":    "/etc/systemd/system/cron.service",
+		"runtime": "/run/systemd/system/runtime.service",
+	} {
+		service, err := NewServiceFromName(name, opts...)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		if info := service.Info(); info.FilePath != filepath.Join(root, expected) || len(info.FileContent) <= 0 {
+			t.Errorf("%s: expected %s, got %s", name, expected, info.FilePath)
+		}
+	}
+
+	if telnet, err := NewServiceFromName("telnet", opts...); err != nil || !telnet.Info().IsMasked {
+		t.Errorf("expected a masked unit, got %v", err)
+	}
+	if _, err := NewServiceFromName("missing", opts...); !errors.Is(err, ErrServiceDoesNotExist) {
+		t.Errorf("expected ErrServiceDoesNotExist, got %v", err)
+	}
+}
+
+func TestSystemdUserUnitSearchPathXDG(t *testing.T) {
+	for name, value := range map[string]string{
+		"XDG_CONFIG_HOME": "/home/u/cfg",
+		"XDG_DATA_HOME":   "relative/is/ignored",
+		"XDG_RUNTIME_DIR": "/run/user/1000",
+		"XDG_CONFIG_DIRS": "",
+		"XDG_DATA_DIRS":   "/opt/share",
+	} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+
+	searchPath := systemdUnitSearchPath(newOptions([]Option{WithScope(ScopeUser)}))
+
+	index := func(dir string) int {
+		for i, item := range searchPath {
+			if item == dir {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	ordered := []string{
+		"/home/u/cfg/systemd/user.control",
+		"/run/user/1000/systemd/transient",
+		"/home/u/cfg/systemd/user",
+		"/etc/xdg/systemd/user",
+		"/etc/systemd/user",
+		"/run/user/1000/systemd/user",
+		"/opt/share/systemd/user",
+		"/usr/lib/systemd/user",
+	}
+	for i := 1; i < len(ordered); i++ {
+		if index(ordered[i-1]) < 0 || index(ordered[i-1]) > index(ordered[i]) {
+			t.Errorf("expected %s before %s in %v", ordered[i-1], ordered[i], searchPath)
+		}
+	}
+
+	if index("/usr/share/systemd/user") >= 0 || index("relative/is/ignored/systemd/user") >= 0 {
+		t.Errorf("unexpected folders in %v", searchPath)
+	}
+
+	if systemdUserConfigDir() != "/home/u/cfg/systemd/user" {
+		t.Errorf("unexpected install folder: %s", systemdUserConfigDir())
+	}
+}
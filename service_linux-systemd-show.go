@@ -0,0 +1,123 @@
+// +build linux
+
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemdInfoProperties - what `InfoContext()` asks `systemctl show` for
+var systemdInfoProperties = []string{
+	"LoadState",
+	"ActiveState",
+	"SubState",
+	"UnitFileState",
+	"MainPID",
+	"ExecMainCode",
+	"ExecMainStatus",
+	"StateChangeTimestamp",
+	"NRestarts",
+	"FragmentPath",
+	"DropInPaths",
+}
+
+// systemdTimerProperties - what `InfoContext()` asks for about the timer of a scheduled service
+var systemdTimerProperties = []string{
+	"LastTriggerUSec",
+	"NextElapseUSecRealtime",
+}
+
+// values of `ExecMainCode=`, these are the `si_code` values of SIGCHLD
+const (
+	systemdExecMainCodeExited = 1
+	systemdExecMainCodeKilled = 2
+	systemdExecMainCodeDumped = 3
+)
+
+// systemdProperties - the `Key=Value` lines of `systemctl show`
+type systemdProperties map[string]string
+
+// parseSystemCtlShow - parses `systemctl show` output, unlike `systemctl status` this is meant for machines
+// and is not localized or truncated. Values can contain `=`, only the first one splits.
+func parseSystemCtlShow(output string) systemdProperties {
+	result := systemdProperties{}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		keyValue := strings.SplitN(line, "=", 2)
+		if len(keyValue) != 2 || len(keyValue[0]) <= 0 {
+			continue
+		}
+
+		result[keyValue[0]] = keyValue[1]
+	}
+
+	return result
+}
+
+func (thisRef systemdProperties) String(key string) string {
+	return thisRef[key]
+}
+
+func (thisRef systemdProperties) Int(key string) int {
+	value, err := strconv.Atoi(thisRef[key])
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+// Time - handles the `--timestamp=unix` `@1611655200` format, the default `Tue 2021-01-26 10:00:00 UTC` only as a fallback
+// for systemd that doesn't know `--timestamp=`, systemd uses an empty value for "never"
+func (thisRef systemdProperties) Time(key string) *time.Time {
+	value := strings.TrimSpace(thisRef[key])
+	if len(value) <= 0 || value == "n/a" {
+		return nil
+	}
+
+	if strings.HasPrefix(value, "@") {
+		seconds, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64)
+		if err != nil {
+			return nil
+		}
+
+		result := time.Unix(seconds, 0)
+		return &result
+	}
+
+	// INFO: fractional seconds, as printed with `--timestamp=us`, are accepted even if the layout has none
+	result, err := time.ParseInLocation("Mon 2006-01-02 15:04:05 MST", value, time.Local)
+	if err != nil {
+		return nil
+	}
+
+	return &result
+}
+
+// applyTo - fills the runtime part of `Info`
+func (thisRef systemdProperties) applyTo(info *Info) {
+	info.PID = thisRef.Int("MainPID")
+	if info.PID <= 0 {
+		info.PID = -1
+	}
+
+	info.Status.State = stateFromActiveState(thisRef.String("ActiveState"))
+	info.Status.SubState = thisRef.String("SubState")
+	info.Status.EnabledState = thisRef.String("UnitFileState")
+	info.Status.Since = thisRef.Time("StateChangeTimestamp")
+	info.Status.RestartCount = thisRef.Int("NRestarts")
+	info.DropIns = strings.Fields(thisRef.String("DropInPaths"))
+
+	switch thisRef.Int("ExecMainCode") {
+	case systemdExecMainCodeKilled, systemdExecMainCodeDumped:
+		info.Status.ExitSignal = thisRef.Int("ExecMainStatus")
+	default:
+		info.Status.ExitCode = thisRef.Int("ExecMainStatus")
+	}
+
+	info.IsRunning = (info.Status.State == StateActive && info.Status.SubState == "running")
+}
@@ -0,0 +1,230 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+const systemctlShowRunning = `MainPID=812
+ExecMainCode=0
+ExecMainStatus=0
+NRestarts=0
+FragmentPath=/lib/systemd/system/nginx.service
+DropInPaths=/etc/systemd/system/nginx.service.d/10-limits.conf /run/systemd/system/nginx.service.d/50-env.conf
+UnitFileState=enabled
+LoadState=loaded
+ActiveState=active
+SubState=running
+StateChangeTimestamp=Tue 2021-01-26 10:00:00 UTC
+`
+
+const systemctlShowKilled = `MainPID=0
+ExecMainCode=2
+ExecMainStatus=9
+NRestarts=3
+FragmentPath=/etc/systemd/system/a-really-long-unit-name-that-systemctl-status-would-ellipsize-in-its-output.service
+UnitFileState=disabled
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+StateChangeTimestamp=@1611655200
+`
+
+const systemctlShowExited = `MainPID=0
+ExecMainCode=1
+ExecMainStatus=203
+NRestarts=0
+FragmentPath=/home/user/.config/systemd/user/worker.service
+UnitFileState=static
+LoadState=loaded
+ActiveState=activating
+SubState=auto-restart
+StateChangeTimestamp=Tue 2021-01-26 10:00:00.123456 UTC
+`
+
+const systemctlShowNotFound = `MainPID=0
+ExecMainCode=0
+ExecMainStatus=0
+NRestarts=0
+FragmentPath=
+UnitFileState=
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+StateChangeTimestamp=
+`
+
+func TestParseSystemCtlShow(t *testing.T) {
+	properties := parseSystemCtlShow("Description=a=b\r\nExecStart={ path=/bin/sh ; argv[]=/bin/sh -c x=1 }\nnot a property\n=orphan value\n")
+
+	if properties.String("Description") != "a=b" {
+		t.Errorf("Description: expected %q, got %q", "a=b", properties.String("Description"))
+	}
+
+	if properties.String("ExecStart") != "{ path=/bin/sh ; argv[]=/bin/sh -c x=1 }" {
+		t.Errorf("ExecStart: got %q", properties.String("ExecStart"))
+	}
+
+	if len(properties) != 2 {
+		t.Errorf("expected 2 properties, got %d: %v", len(properties), properties)
+	}
+}
+
+func TestSystemdPropertiesApplyTo(t *testing.T) {
+	testTime := time.Date(2021, 1, 26, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		output string
+		want   Info
+	}{
+		{
+			name:   "running",
+			output: systemctlShowRunning,
+			want: Info{
+				IsRunning: true,
+				PID:       812,
+				DropIns:   []string{"/etc/systemd/system/nginx.service.d/10-limits.conf", "/run/systemd/system/nginx.service.d/50-env.conf"},
+				Status: Status{
+					State:        StateActive,
+					SubState:     "running",
+					Since:        &testTime,
+					EnabledState: "enabled",
+				},
+			},
+		},
+		{
+			name:   "killed by signal",
+			output: systemctlShowKilled,
+			want: Info{
+				PID: -1,
+				Status: Status{
+					State:        StateFailed,
+					SubState:     "failed",
+					ExitSignal:   9,
+					Since:        &testTime,
+					RestartCount: 3,
+					EnabledState: "disabled",
+				},
+			},
+		},
+		{
+			name:   "exited with code",
+			output: systemctlShowExited,
+			want: Info{
+				PID: -1,
+				Status: Status{
+					State:        StateActivating,
+					SubState:     "auto-restart",
+					ExitCode:     203,
+					Since:        func() *time.Time { t := testTime.Add(123456 * time.Microsecond); return &t }(),
+					EnabledState: "static",
+				},
+			},
+		},
+		{
+			name:   "not found",
+			output: systemctlShowNotFound,
+			want: Info{
+				PID: -1,
+				Status: Status{
+					State:    StateInactive,
+					SubState: "dead",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Info{}
+			parseSystemCtlShow(test.output).applyTo(&got)
+
+			if got.IsRunning != test.want.IsRunning {
+				t.Errorf("IsRunning: expected %v, got %v", test.want.IsRunning, got.IsRunning)
+			}
+			if got.PID != test.want.PID {
+				t.Errorf("PID: expected %d, got %d", test.want.PID, got.PID)
+			}
+			if strings.Join(got.DropIns, " ") != strings.Join(test.want.DropIns, " ") {
+				t.Errorf("DropIns: expected %v, got %v", test.want.DropIns, got.DropIns)
+			}
+			if got.Status.State != test.want.Status.State {
+				t.Errorf("State: expected %s, got %s", test.want.Status.State, got.Status.State)
+			}
+			if got.Status.SubState != test.want.Status.SubState {
+				t.Errorf("SubState: expected %s, got %s", test.want.Status.SubState, got.Status.SubState)
+			}
+			if got.Status.ExitCode != test.want.Status.ExitCode {
+				t.Errorf("ExitCode: expected %d, got %d", test.want.Status.ExitCode, got.Status.ExitCode)
+			}
+			if got.Status.ExitSignal != test.want.Status.ExitSignal {
+				t.Errorf("ExitSignal: expected %d, got %d", test.want.Status.ExitSignal, got.Status.ExitSignal)
+			}
+			if got.Status.RestartCount != test.want.Status.RestartCount {
+				t.Errorf("RestartCount: expected %d, got %d", test.want.Status.RestartCount, got.Status.RestartCount)
+			}
+			if got.Status.EnabledState != test.want.Status.EnabledState {
+				t.Errorf("EnabledState: expected %s, got %s", test.want.Status.EnabledState, got.Status.EnabledState)
+			}
+			if (got.Status.Since == nil) != (test.want.Status.Since == nil) {
+				t.Fatalf("Since: expected %v, got %v", test.want.Status.Since, got.Status.Since)
+			}
+			if got.Status.Since != nil && !got.Status.Since.Equal(*test.want.Status.Since) {
+				t.Errorf("Since: expected %v, got %v", *test.want.Status.Since, *got.Status.Since)
+			}
+		})
+	}
+}
+
+func TestSystemdInfoThroughExecutor(t *testing.T) {
+	commands := [][]string{}
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, append([]string{name}, args...))
+		return systemctlShowRunning, nil
+	})
+
+	info := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "nginx"}, newOptions([]Option{WithExecutor(executor)})).Info()
+	if info.Error != nil {
+		t.Fatalf("unexpected error: %v", info.Error)
+	}
+
+	if len(commands) != 1 || commands[0][0] != "systemctl" || !strings.Contains(strings.Join(commands[0], " "), "show --timestamp=unix") {
+		t.Fatalf("expected a single `systemctl show --timestamp=unix`, got %v", commands)
+	}
+
+	if !info.IsRunning || info.PID != 812 || info.FilePath != "/lib/systemd/system/nginx.service" {
+		t.Errorf("unexpected info: running %v, pid %d, path %s", info.IsRunning, info.PID, info.FilePath)
+	}
+}
+
+func TestSystemdInfoWithoutUnixTimestamps(t *testing.T) {
+	commands := [][]string{}
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, append([]string{name}, args...))
+		if args[1] == "--timestamp=unix" {
+			return "systemctl: unrecognized option '--timestamp=unix'", errors.New("exit status 1")
+		}
+
+		return systemctlShowRunning, nil
+	})
+
+	info := newServiceFromSERVICE_SystemD(spec.SERVICE{Name: "nginx"}, newOptions([]Option{WithExecutor(executor)})).Info()
+	if info.Error != nil {
+		t.Fatalf("unexpected error: %v", info.Error)
+	}
+
+	if len(commands) != 2 || strings.Contains(strings.Join(commands[1], " "), "--timestamp") {
+		t.Fatalf("expected a retry without `--timestamp`, got %v", commands)
+	}
+	if !info.IsRunning || info.PID != 812 {
+		t.Errorf("unexpected info: running %v, pid %d", info.IsRunning, info.PID)
+	}
+}
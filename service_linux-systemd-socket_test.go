@@ -0,0 +1,129 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdControlUnits(t *testing.T) {
+	echoSpec := spec.NewEmptySERVICE()
+	echoSpec.Name = "echo"
+	echoSpec.Executable = "/usr/bin/echo-server"
+
+	for _, testCase := range []struct {
+		name    string
+		option  Option
+		restart []string
+		reload  []string
+	}{
+		{"plain", WithScope(ScopeSystem), []string{"systemctl restart echo"}, []string{"systemctl reload echo"}},
+		{"socket", WithSocket(Socket{ListenStream: []string{"7"}}), []string{"systemctl restart echo.socket", "systemctl restart echo"}, []string{"systemctl reload echo"}},
+		{"accept", WithSocket(Socket{ListenStream: []string{"7"}, Accept: true}), []string{"systemctl restart echo.socket"}, nil},
+		{"timer", WithSchedule(Schedule{Every: time.Hour}), []string{"systemctl restart echo.timer"}, []string{"systemctl reload echo"}},
+	} {
+		commands := []string{}
+		executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+			if args[0] != "daemon-reload" {
+				commands = append(commands, name+" "+strings.Join(args, " "))
+			}
+			return "", nil
+		})
+
+		echo, err := NewServiceFromSERVICE(echoSpec, WithExecutor(executor), WithInitType(spec.InitSystemd), WithScope(ScopeSystem), testCase.option)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.name, err)
+		}
+
+		if err := echo.Restart(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.name, err)
+		}
+		if strings.Join(commands, ", ") != strings.Join(testCase.restart, ", ") {
+			t.Errorf("%s: unexpected restart commands: %v", testCase.name, commands)
+		}
+
+		commands = []string{}
+		err = echo.Reload()
+		if testCase.reload == nil && !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("%s: expected ErrServiceUnsupportedRequest, got %v", testCase.name, err)
+		} else if testCase.reload != nil && err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.name, err)
+		}
+		if strings.Join(commands, ", ") != strings.Join(testCase.reload, ", ") {
+			t.Errorf("%s: unexpected reload commands: %v", testCase.name, commands)
+		}
+	}
+}
+
+func TestSystemdSocketUnits(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-socket")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	echoSpec := spec.NewEmptySERVICE()
+	echoSpec.Name = "echo"
+	echoSpec.Description = "Echo"
+	echoSpec.Executable = "/usr/bin/echo-server"
+
+	for _, testCase := range []struct {
+		socket      Socket
+		serviceFile string
+		expected    []string
+	}{
+		{Socket{ListenStream: []string{"7"}, ListenDatagram: []string{"/run/echo.dgram"}, FileDescriptorName: "echo"}, "echo.service", []string{"Description=Echo socket\n", "ListenStream=7\n", "ListenDatagram=/run/echo.dgram\n", "FileDescriptorName=echo\n", "WantedBy=sockets.target\n"}},
+		{Socket{ListenStream: []string{"127.0.0.1:7"}, Accept: true}, "echo@.service", []string{"ListenStream=127.0.0.1:7\n", "Accept=yes\n"}},
+	} {
+		echo, err := NewServiceFromSERVICE(echoSpec, WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemd), WithSocket(testCase.socket))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.serviceFile, err)
+		}
+		if err := echo.Install(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.serviceFile, err)
+		}
+
+		serviceFile := filepath.Join(root, "/etc/systemd/system", testCase.serviceFile)
+		socketFile := filepath.Join(root, "/etc/systemd/system/echo.socket")
+		if _, err := os.Stat(serviceFile); err != nil {
+			t.Errorf("%s: expected the service unit, got %v", testCase.serviceFile, err)
+		}
+
+		socketContent, _ := ioutil.ReadFile(socketFile)
+		for _, expected := range testCase.expected {
+			if !strings.Contains(string(socketContent), expected) {
+				t.Errorf("%s: expected %q in:\n%s", testCase.serviceFile, expected, socketContent)
+			}
+		}
+		if !testCase.socket.Accept && strings.Contains(string(socketContent), "Accept=") {
+			t.Errorf("%s: unexpected Accept= in:\n%s", testCase.serviceFile, socketContent)
+		}
+
+		// INFO: the socket is what starts at boot, not the service
+		if err := echo.Enable(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.serviceFile, err)
+		}
+		wantsLink := filepath.Join(root, "/etc/systemd/system/sockets.target.wants/echo.socket")
+		if _, err := os.Lstat(wantsLink); err != nil {
+			t.Errorf("%s: expected the socket enabled, got %v", testCase.serviceFile, err)
+		}
+
+		if err := echo.Uninstall(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", testCase.serviceFile, err)
+		}
+		for _, path := range []string{serviceFile, socketFile, wantsLink} {
+			if _, err := os.Lstat(path); !os.IsNotExist(err) {
+				t.Errorf("%s: expected %s removed, got %v", testCase.serviceFile, path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+	"github.com/codemodify/systemkit-service/helpers"
+)
+
+// transientSystemdService - a unit started without a unit file, systemd unloads it once it exits.
+// Stop, Info and Logs work as for installed services, there is nothing to install, enable or start again.
+// INFO: `unit` is not embedded, drop-ins, masks and instances need a unit file
+type transientSystemdService struct {
+	unit systemdService
+}
+
+func runTransient_SystemD(ctx context.Context, serviceSpec spec.SERVICE, opts options) (Service, error) {
+	if opts.isOffline() {
+		return nil, opts.errOffline("run")
+	}
+
+	if len(serviceSpec.Name) <= 0 {
+		serviceSpec.Name = fmt.Sprintf("run-%d", time.Now().UnixNano())
+	}
+
+	logging.Debugf("%s: running transient: %s", logTagSystemD, helpers.AsJSONString(serviceSpec))
+
+	result := &transientSystemdService{
+		unit: systemdService{
+			serviceSpec:            serviceSpec,
+			useConfigAsFileContent: true,
+			opts:                   opts,
+		},
+	}
+
+	err := result.unit.manager().startTransientUnit(ctx, serviceSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (thisRef transientSystemdService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef transientSystemdService) InstallContext(ctx context.Context) error {
+	return thisRef.errTransient("install")
+}
+
+func (thisRef transientSystemdService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+// UninstallContext - stops the service if it still runs, there are no files to remove
+func (thisRef transientSystemdService) UninstallContext(ctx context.Context) error {
+	err := thisRef.StopContext(ctx)
+	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef transientSystemdService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef transientSystemdService) StartContext(ctx context.Context) error {
+	return thisRef.errTransient("start")
+}
+
+func (thisRef transientSystemdService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef transientSystemdService) StopContext(ctx context.Context) error {
+	logging.Debugf("stopping transient unit with systemd")
+	return thisRef.unit.manager().stopUnit(ctx, thisRef.unit.serviceSpec.Name)
+}
+
+func (thisRef transientSystemdService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef transientSystemdService) RestartContext(ctx context.Context) error {
+	return thisRef.errTransient("restart")
+}
+
+func (thisRef transientSystemdService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef transientSystemdService) ReloadContext(ctx context.Context) error {
+	return thisRef.errTransient("reload")
+}
+
+func (thisRef transientSystemdService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+func (thisRef transientSystemdService) EnableContext(ctx context.Context) error {
+	return thisRef.errTransient("enable")
+}
+
+func (thisRef transientSystemdService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+func (thisRef transientSystemdService) DisableContext(ctx context.Context) error {
+	return thisRef.errTransient("disable")
+}
+
+func (thisRef transientSystemdService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef transientSystemdService) IsEnabledContext(ctx context.Context) (bool, error) {
+	return false, thisRef.errTransient("is-enabled")
+}
+
+func (thisRef transientSystemdService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+// InfoContext - `FilePath` is where systemd keeps the generated unit while it is loaded, ex: `/run/systemd/transient`
+func (thisRef transientSystemdService) InfoContext(ctx context.Context) Info {
+	result := thisRef.unit.InfoContext(ctx)
+	if result.FilePath == thisRef.unit.filePath() {
+		result.FilePath = ""
+	}
+
+	return result
+}
+
+func (thisRef transientSystemdService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.unit.Logs(since, limit)
+}
+
+func (thisRef transientSystemdService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.unit.LogsContext(ctx, since, limit)
+}
+
+func (thisRef transientSystemdService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	return thisRef.unit.FollowLogs(ctx)
+}
+
+func (thisRef transientSystemdService) errTransient(operation string) error {
+	return fmt.Errorf("%w: %s: %s is transient, run it again with RunTransient()", ErrServiceUnsupportedRequest, operation, thisRef.unit.serviceSpec.Name)
+}
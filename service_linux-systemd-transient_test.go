@@ -0,0 +1,111 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestRunTransient(t *testing.T) {
+	commands := []string{}
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "Running as unit: migrate.service\n", nil
+	})
+
+	migrate := spec.SERVICE{
+		Name:             "migrate",
+		Description:      "Schema migration",
+		Executable:       "/usr/bin/migrate",
+		Args:             []string{"up", "--all"},
+		WorkingDirectory: "/srv/app",
+		Environment:      map[string]string{"DB": "main", "A": "1"},
+		Credentials:      spec.CredentialsConfig{User: "app"},
+	}
+
+	transient, err := RunTransient(migrate, WithExecutor(executor), WithInitType(spec.InitSystemd), WithScope(ScopeSystem))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "systemd-run --unit migrate.service --collect --description Schema migration --property WorkingDirectory=/srv/app --setenv A=1 --setenv DB=main --uid app -- /usr/bin/migrate up --all"
+	if len(commands) != 1 || commands[0] != expected {
+		t.Errorf("unexpected commands: %v", commands)
+	}
+
+	if err := transient.Install(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest installing a transient service, got %v", err)
+	}
+
+	if err := transient.Stop(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commands[len(commands)-1] != "systemctl stop migrate" {
+		t.Errorf("unexpected stop command: %s", commands[len(commands)-1])
+	}
+
+	if _, err := RunTransient(migrate, WithExecutor(executor), WithInitType(spec.InitUpstart)); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest on upstart, got %v", err)
+	}
+}
+
+func TestRunTransientOptions(t *testing.T) {
+	commands := []string{}
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", nil
+	})
+
+	worker := spec.SERVICE{Name: "worker", Executable: "/usr/bin/worker"}
+	opts := []Option{WithExecutor(executor), WithInitType(spec.InitSystemd), WithScope(ScopeSystem)}
+
+	transient, err := RunTransient(worker, append(opts, WithNotify(30*time.Second), WithLimits(Limits{TasksMax: 8}))...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "systemd-run --unit worker.service --collect --property Type=notify --property WatchdogSec=30 --property TasksMax=8 -- /usr/bin/worker"
+	if len(commands) != 1 || commands[0] != expected {
+		t.Errorf("unexpected commands: %v", commands)
+	}
+
+	if err := transient.Restart(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest restarting a transient service, got %v", err)
+	}
+	if err := transient.Reload(); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest reloading a transient service, got %v", err)
+	}
+	if isEnabled, err := transient.IsEnabled(); isEnabled || !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected ErrServiceUnsupportedRequest asking if a transient service is enabled, got %v, %v", isEnabled, err)
+	}
+	if _, ok := transient.(DropInManager); ok {
+		t.Errorf("expected no drop-ins for a transient service")
+	}
+	if _, ok := transient.(Masker); ok {
+		t.Errorf("expected no masking for a transient service")
+	}
+	if _, ok := transient.(Templater); ok {
+		t.Errorf("expected no instances for a transient service")
+	}
+	if _, ok := transient.(LogReader); !ok {
+		t.Errorf("expected logs for a transient service")
+	}
+
+	for _, option := range []Option{
+		WithSocket(Socket{ListenStream: []string{"8080"}}),
+		WithSchedule(Schedule{Every: time.Hour}),
+	} {
+		if _, err := RunTransient(worker, append(opts, option)...); !errors.Is(err, ErrServiceUnsupportedRequest) {
+			t.Errorf("expected ErrServiceUnsupportedRequest, got %v", err)
+		}
+	}
+	if len(commands) != 1 {
+		t.Errorf("expected nothing run for rejected options, got %v", commands)
+	}
+}
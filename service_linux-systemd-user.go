@@ -0,0 +1,147 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// systemdLingerDir - logind starts the user manager at boot for every user with a file here
+const systemdLingerDir = "/var/lib/systemd/linger"
+
+// systemdUserManagerUnreachable - what `systemctl --user` prints when it can't find the user manager
+var systemdUserManagerUnreachable = []string{
+	"Failed to connect to bus",
+	"Failed to connect to user scope bus",
+	"$DBUS_SESSION_BUS_ADDRESS and $XDG_RUNTIME_DIR not defined",
+}
+
+// userManagerEnv - `systemctl --user` and the session bus find the user manager through `$XDG_RUNTIME_DIR`
+// and `$DBUS_SESSION_BUS_ADDRESS`, both are missing under `sudo -u`, `su` or cron, this has the missing ones
+// as `KEY=value` pairs pointing where logind put them. The process environment is left alone.
+func userManagerEnv() []string {
+	result := []string{}
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if len(runtimeDir) <= 0 {
+		candidate := filepath.Join("/run/user", strconv.Itoa(os.Getuid()))
+		if _, err := os.Stat(candidate); err != nil {
+			return result
+		}
+
+		result = append(result, "XDG_RUNTIME_DIR="+candidate)
+		runtimeDir = candidate
+	}
+
+	if len(os.Getenv("DBUS_SESSION_BUS_ADDRESS")) <= 0 {
+		busPath := filepath.Join(runtimeDir, "bus")
+		if _, err := os.Stat(busPath); err != nil {
+			return result
+		}
+
+		result = append(result, "DBUS_SESSION_BUS_ADDRESS=unix:path="+busPath)
+	}
+
+	return result
+}
+
+// userManagerCommand - `name` and `args`, run through `env` when the user manager's environment is incomplete
+func userManagerCommand(name string, args []string) (string, []string) {
+	env := userManagerEnv()
+	if len(env) <= 0 {
+		return name, args
+	}
+
+	logging.Debugf("%s: running %s with: %s", logTagSystemD, name, strings.Join(env, " "))
+
+	return "env", append(append(env, name), args...)
+}
+
+// userManagerBusAddress - `$DBUS_SESSION_BUS_ADDRESS`, or the bus logind put in the user's runtime folder
+func userManagerBusAddress() string {
+	for _, variable := range userManagerEnv() {
+		if strings.HasPrefix(variable, "DBUS_SESSION_BUS_ADDRESS=") {
+			return strings.TrimPrefix(variable, "DBUS_SESSION_BUS_ADDRESS=")
+		}
+	}
+
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+}
+
+// isUserManagerUnreachable - whether `output` says there is no user manager, as opposed to a failed operation
+func isUserManagerUnreachable(output string) bool {
+	for _, marker := range systemdUserManagerUnreachable {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// userManagerUnreachableError - `errors.Is()` matches `ErrUserManagerUnreachable`, `errors.As()` reaches the
+// `*OperationError` in `Err`
+type userManagerUnreachableError struct {
+	UID int
+	Err error
+}
+
+func (thisRef *userManagerUnreachableError) Error() string {
+	return fmt.Sprintf("%s: no user manager runs for uid %d, the user has to be logged in or lingering, see WithLinger(): %v", ErrUserManagerUnreachable.Error(), thisRef.UID, thisRef.Err)
+}
+
+func (thisRef *userManagerUnreachableError) Is(target error) bool {
+	return target == ErrUserManagerUnreachable
+}
+
+func (thisRef *userManagerUnreachableError) Unwrap() error {
+	return thisRef.Err
+}
+
+// errUserManagerUnreachable - `ErrUserManagerUnreachable` with what to do about it, `err` stays reachable
+func errUserManagerUnreachable(err error) error {
+	return &userManagerUnreachableError{UID: os.Getuid(), Err: err}
+}
+
+// enableLinger - `loginctl enable-linger` for the current user, or the file it creates `WithRoot()`
+func (thisRef systemdService) enableLinger(ctx context.Context) error {
+	currentUser, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	// 1.
+	if thisRef.opts.isOffline() {
+		lingerFile := thisRef.opts.rooted(filepath.Join(systemdLingerDir, currentUser.Username))
+
+		logging.Debugf("%s: enabling linger: %s", logTagSystemD, lingerFile)
+		os.MkdirAll(filepath.Dir(lingerFile), os.ModePerm)
+
+		return ioutil.WriteFile(lingerFile, []byte{}, 0644)
+	}
+
+	// 2. already on, `loginctl` may ask for authorization even if nothing changes
+	if _, err := os.Stat(filepath.Join(systemdLingerDir, currentUser.Username)); err == nil {
+		return nil
+	}
+
+	args := []string{"enable-linger", currentUser.Username}
+	logging.Debugf("%s: RUN-LOGINCTL: loginctl %s", logTagSystemD, strings.Join(args, " "))
+
+	output, err := thisRef.opts.executor.Exec(ctx, "loginctl", args...)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return newOperationError(string(spec.InitSystemd), "enable-linger", append([]string{"loginctl"}, args...), output, err)
+}
@@ -0,0 +1,110 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdLingerOffline(t *testing.T) {
+	root, err := ioutil.TempDir("", "systemkit-linger")
+	if err != nil {
+		t.Fatalf("can't create root: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	serviceSpec := spec.NewEmptySERVICE()
+	serviceSpec.Name = "syncer"
+	serviceSpec.Executable = "/usr/bin/syncer"
+
+	if _, err := NewServiceFromSERVICE(serviceSpec, WithRoot(root), WithScope(ScopeSystem), WithInitType(spec.InitSystemd), WithLinger()); !errors.Is(err, ErrServiceUnsupportedRequest) {
+		t.Errorf("expected lingering to need a user service, got %v", err)
+	}
+
+	syncer, err := NewServiceFromSERVICE(serviceSpec, WithRoot(root), WithScope(ScopeUser), WithInitType(spec.InitSystemd), WithLinger())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := syncer.Install(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, systemdLingerDir, currentUser.Username)); err != nil {
+		t.Errorf("expected a linger file: %v", err)
+	}
+}
+
+func TestSystemdUserManagerUnreachable(t *testing.T) {
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		return "Failed to connect to bus: No medium found\n", errors.New("exit status 1")
+	})
+
+	manager := systemctlManager{opts: newOptions([]Option{WithExecutor(executor), WithScope(ScopeUser)})}
+	err := manager.startUnit(context.Background(), "syncer")
+	if !errors.Is(err, ErrUserManagerUnreachable) {
+		t.Errorf("expected ErrUserManagerUnreachable, got %v", err)
+	}
+	var operationError *OperationError
+	if !errors.As(err, &operationError) || operationError.Operation != "start" {
+		t.Errorf("expected the OperationError kept, got %v", err)
+	}
+
+	// INFO: the same output for system services is a different problem
+	manager = systemctlManager{opts: newOptions([]Option{WithExecutor(executor), WithScope(ScopeSystem)})}
+	if err := manager.startUnit(context.Background(), "syncer"); err == nil || errors.Is(err, ErrUserManagerUnreachable) {
+		t.Errorf("expected a plain operation error, got %v", err)
+	}
+}
+
+func TestUserManagerEnv(t *testing.T) {
+	runtimeDir, err := ioutil.TempDir("", "systemkit-runtime")
+	if err != nil {
+		t.Fatalf("can't create runtime dir: %v", err)
+	}
+	defer os.RemoveAll(runtimeDir)
+
+	if err := ioutil.WriteFile(filepath.Join(runtimeDir, "bus"), []byte{}, 0644); err != nil {
+		t.Fatalf("can't create bus: %v", err)
+	}
+
+	for _, name := range []string{"XDG_RUNTIME_DIR", "DBUS_SESSION_BUS_ADDRESS"} {
+		defer os.Setenv(name, os.Getenv(name))
+	}
+	os.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+
+	commands := []string{}
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", nil
+	})
+
+	manager := systemctlManager{opts: newOptions([]Option{WithExecutor(executor), WithScope(ScopeUser)})}
+	if err := manager.startUnit(context.Background(), "syncer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "env DBUS_SESSION_BUS_ADDRESS=unix:path=" + filepath.Join(runtimeDir, "bus") + " systemctl --user start syncer"
+	if len(commands) != 1 || commands[0] != expected {
+		t.Errorf("unexpected commands: %v", commands)
+	}
+	if address := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); len(address) > 0 {
+		t.Errorf("expected the process environment untouched, got %s", address)
+	}
+	if address := userManagerBusAddress(); address != "unix:path="+filepath.Join(runtimeDir, "bus") {
+		t.Errorf("unexpected bus address: %s", address)
+	}
+}
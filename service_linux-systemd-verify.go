@@ -0,0 +1,137 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	logging "github.com/codemodify/systemkit-logging"
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+// systemdUnitFile - a unit `InstallContext()` writes, ex: the service and its socket or timer
+type systemdUnitFile struct {
+	path    string
+	content string
+}
+
+// verifyUnits - runs `systemd-analyze verify` on `unitFiles` from a temp folder, nothing is installed if it finds problems in any of them.
+// INFO: `systemd-analyze` also checks that the binaries exist, `WithRoot()` installs for another filesystem so it is skipped there
+func (thisRef systemdService) verifyUnits(ctx context.Context, unitFiles []systemdUnitFile) error {
+	if thisRef.opts.isOffline() {
+		logging.Debugf("%s: skipping unit verification for %s", logTagSystemD, thisRef.opts.root)
+		return nil
+	}
+
+	// 1. the file names are what tell `systemd-analyze` the unit names and types, a socket or a timer finds its service next to it
+	dir, err := ioutil.TempDir("", "systemkit-verify")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	unitPaths := []string{}
+	for _, unitFile := range unitFiles {
+		unitPath := filepath.Join(dir, filepath.Base(unitFile.path))
+		err = ioutil.WriteFile(unitPath, []byte(unitFile.content), 0644)
+		if err != nil {
+			return err
+		}
+
+		unitPaths = append(unitPaths, unitPath)
+	}
+
+	// 2.
+	args := append([]string{"verify"}, unitPaths...)
+	if thisRef.opts.isUserScope() {
+		args = append([]string{"--user"}, args...)
+	}
+
+	logging.Debugf("%s: RUN-SYSTEMD-ANALYZE: systemd-analyze %s", logTagSystemD, strings.Join(args, " "))
+
+	output, err := thisRef.opts.executor.Exec(ctx, "systemd-analyze", args...)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		logging.Debugf("%s: systemd-analyze is missing, skipping unit verification", logTagSystemD)
+		return nil
+	}
+
+	// 3. unknown keys and sections are only warnings for systemd, they are still typos
+	for _, unitPath := range unitPaths {
+		diagnostics := parseSystemdAnalyzeVerify(output, unitPath)
+		if len(diagnostics) > 0 {
+			return &ValidationError{
+				Backend:     string(spec.InitSystemd),
+				Unit:        filepath.Base(unitPath),
+				Diagnostics: diagnostics,
+				Output:      output,
+			}
+		}
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	diagnostics := []Diagnostic{{Message: strings.TrimSpace(output)}}
+	if len(diagnostics[0].Message) <= 0 {
+		diagnostics[0].Message = err.Error()
+	}
+
+	return &ValidationError{
+		Backend:     string(spec.InitSystemd),
+		Unit:        filepath.Base(unitPaths[0]),
+		Diagnostics: diagnostics,
+		Output:      output,
+	}
+}
+
+// parseSystemdAnalyzeVerify - the lines about the unit at `unitPath`, ex: `/tmp/x/nginx.service:4: Unknown key 'ExecStar' ...`
+// or `nginx.service: Command /usr/bin/nginx is not executable ...`, lines about other units, ex: its dependencies, are skipped
+func parseSystemdAnalyzeVerify(output string, unitPath string) []Diagnostic {
+	result := []Diagnostic{}
+
+	unitName := filepath.Base(unitPath)
+	unitExt := filepath.Ext(unitName)
+	unitPrefix := strings.TrimSuffix(unitName, unitExt)
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		// 1. `<path>:<line>: <message>`
+		if strings.HasPrefix(line, unitPath+":") {
+			rest := strings.TrimPrefix(line, unitPath+":")
+
+			parts := strings.SplitN(rest, ": ", 2)
+			if lineNumber, err := strconv.Atoi(parts[0]); err == nil && len(parts) == 2 {
+				result = append(result, Diagnostic{Line: lineNumber, Message: parts[1]})
+			} else {
+				result = append(result, Diagnostic{Message: strings.TrimSpace(rest)})
+			}
+
+			continue
+		}
+
+		// 2. `<unit>: <message>`, templates are verified as an instance, ex: `worker@i.service`
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		name := parts[0]
+		if name == unitName || (strings.HasSuffix(unitPrefix, "@") && strings.HasPrefix(name, unitPrefix) && filepath.Ext(name) == unitExt) {
+			result = append(result, Diagnostic{Message: parts[1]})
+		}
+	}
+
+	return result
+}
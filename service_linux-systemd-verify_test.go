@@ -0,0 +1,128 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	spec "github.com/codemodify/systemkit-service-spec"
+)
+
+func TestSystemdVerifyUnits(t *testing.T) {
+	var command []string
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		command = append([]string{name}, args...)
+		unitPath := args[len(args)-1]
+
+		return unitPath + ":4: Unknown key 'ExecStar' in section [Service], ignoring.\n" +
+			"/lib/systemd/system/other.service:9: Unknown key 'Foo' in section [Service], ignoring.\n" +
+			"nginx.service: Command /usr/sbin/nginx is not executable: No such file or directory\n" +
+			"other.service: Command /bin/other is not executable: No such file or directory\n", exec.ErrNotFound
+	})
+
+	nginx := systemdService{
+		serviceSpec: spec.SERVICE{Name: "nginx"},
+		opts:        newOptions([]Option{WithExecutor(executor), WithScope(ScopeSystem)}),
+	}
+
+	// INFO: `exec.ErrNotFound` means there is no `systemd-analyze` to ask
+	if err := nginx.verifyUnits(context.Background(), []systemdUnitFile{{path: "/etc/systemd/system/nginx.service", content: "[Service]\n"}}); err != nil {
+		t.Fatalf("expected verification to be skipped, got %v", err)
+	}
+
+	nginx.opts.executor = ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		output, _ := executor(ctx, name, args...)
+		return output, errors.New("exit status 1")
+	})
+
+	err := nginx.verifyUnits(context.Background(), []systemdUnitFile{{path: "/etc/systemd/system/nginx.service", content: "[Service]\nExecStar=/usr/sbin/nginx\n"}})
+	if command[0] != "systemd-analyze" || command[1] != "verify" || !strings.HasSuffix(command[2], "/nginx.service") {
+		t.Errorf("unexpected command: %v", command)
+	}
+
+	if !errors.Is(err, ErrServiceConfigError) {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a *ValidationError, got %T", err)
+	}
+
+	expected := []Diagnostic{
+		{Line: 4, Message: "Unknown key 'ExecStar' in section [Service], ignoring."},
+		{Message: "Command /usr/sbin/nginx is not executable: No such file or directory"},
+	}
+	if validationErr.Unit != "nginx.service" || len(validationErr.Diagnostics) != len(expected) {
+		t.Fatalf("unexpected error: %+v", validationErr)
+	}
+	for i := range expected {
+		if validationErr.Diagnostics[i] != expected[i] {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, expected[i], validationErr.Diagnostics[i])
+		}
+	}
+}
+
+func TestSystemdVerifyBeforeWriting(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "systemkit-verify")
+	if err != nil {
+		t.Fatalf("can't create folder: %v", err)
+	}
+	defer os.RemoveAll(configDir)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", configDir)
+
+	// INFO: only the socket is broken, the service is fine on its own
+	var command []string
+	executor := ExecutorFunc(func(ctx context.Context, name string, args ...string) (string, error) {
+		command = append([]string{name}, args...)
+		for _, arg := range args {
+			if strings.HasSuffix(arg, "/echo.socket") {
+				return arg + ":5: Unknown key 'ListenStrem' in section [Socket], ignoring.\n", errors.New("exit status 1")
+			}
+		}
+
+		return "", nil
+	})
+
+	echoSpec := spec.NewEmptySERVICE()
+	echoSpec.Name = "echo"
+	echoSpec.Executable = "/usr/bin/echo-server"
+
+	echo := newServiceFromSERVICE_SystemD(echoSpec, newOptions([]Option{WithExecutor(executor), WithScope(ScopeUser), WithVerify(true), WithSocket(Socket{ListenStream: []string{"7"}})}))
+
+	err = echo.Install()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Unit != "echo.socket" {
+		t.Fatalf("expected a *ValidationError for the socket, got %v", err)
+	}
+	if len(command) != 5 || command[1] != "--user" || !strings.HasSuffix(command[3], "/echo.service") || !strings.HasSuffix(command[4], "/echo.socket") {
+		t.Errorf("expected both units verified at once, got %v", command)
+	}
+
+	for _, unitFile := range []string{"echo.service", "echo.socket"} {
+		if _, err := os.Stat(filepath.Join(configDir, "systemd/user", unitFile)); !os.IsNotExist(err) {
+			t.Errorf("expected nothing installed, got %s: %v", unitFile, err)
+		}
+	}
+}
+
+func TestParseSystemdAnalyzeVerifyTemplate(t *testing.T) {
+	output := "/tmp/x/worker@.service:6: Failed to parse service restart specifier, ignoring: bogus\n" +
+		"worker@i.service: Command /bin/nope is not executable: No such file or directory\n" +
+		"workers.service: not ours\n"
+
+	diagnostics := parseSystemdAnalyzeVerify(output, "/tmp/x/worker@.service")
+	if len(diagnostics) != 2 || diagnostics[0].Line != 6 || diagnostics[1].Line != 0 {
+		t.Errorf("unexpected diagnostics: %+v", diagnostics)
+	}
+}
@@ -3,11 +3,14 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 
 	logging "github.com/codemodify/systemkit-logging"
 	encoders "github.com/codemodify/systemkit-service-encoders-systemd"
@@ -21,55 +24,93 @@ type systemdService struct {
 	serviceSpec            spec.SERVICE
 	useConfigAsFileContent bool
 	fileContentTemplate    string
+	opts                   options
+	templateName           string // set for instances, the template they run from, ex: `worker@`
+	loadedFrom             string // set for services read from disk, the unit file found on the search path
 }
 
-func newServiceFromSERVICE_SystemD(serviceSpec spec.SERVICE) Service {
+func newServiceFromSERVICE_SystemD(serviceSpec spec.SERVICE, opts options) Service {
 	logging.Debugf("%s: spec.SERVICE object: %s", logTagSystemD, helpers.AsJSONString(serviceSpec))
 
 	return &systemdService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: true,
+		opts:                   opts,
 	}
 }
 
-func newServiceFromName_SystemD(name string) (Service, error) {
-	fileContent := []byte{}
-	var err error
-
-	if helpers.IsRoot() {
-		serviceFile := filepath.Join("/etc/systemd/system", name+".service")
-		fileContent, err = ioutil.ReadFile(serviceFile)
-		if err != nil {
-			serviceFile = filepath.Join("/usr/lib/systemd/system", name+".service")
-			fileContent, err = ioutil.ReadFile(serviceFile)
+func newServiceFromName_SystemD(name string, opts options) (Service, error) {
+	// 1. the unit file, an instance may have one of its own, ex: `worker@tenantA.service`
+	serviceFile, err := findSystemdUnitFile(name+".service", opts)
+	if err == ErrServiceDoesNotExist {
+		// INFO: instances usually have no unit file of their own, they share the template's
+		if template, instance, ok := splitInstanceName(name); ok {
+			templateService, err := newServiceFromName_SystemD(template, opts)
 			if err != nil {
-				return nil, ErrServiceDoesNotExist
+				return nil, err
 			}
+
+			return templateService.(*systemdService).Instance(instance)
 		}
-	} else {
-		serviceFile := filepath.Join(helpers.HomeDir(""), ".config/systemd/user", name+".service")
-		fileContent, err = ioutil.ReadFile(serviceFile)
-		if err != nil {
-			return nil, ErrServiceDoesNotExist
-		}
+	}
+	if err == errSystemdUnitMasked {
+		// INFO: only the mask is left, enough to `Unmask()` it
+		return newServiceFromPlatformTemplate_SystemD(name, "", opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 2.
+	logging.Debugf("%s: loading %s from: %s", logTagSystemD, name, serviceFile)
+
+	fileContent, err := ioutil.ReadFile(serviceFile)
+	if err != nil {
+		return nil, ErrServiceDoesNotExist
 	}
 
-	return newServiceFromPlatformTemplate_SystemD(name, string(fileContent))
+	service, err := newServiceFromPlatformTemplate_SystemD(name, string(fileContent), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	service.(*systemdService).loadedFrom = serviceFile
+
+	return service, nil
 }
 
-func newServiceFromPlatformTemplate_SystemD(name string, template string) (Service, error) {
+func newServiceFromPlatformTemplate_SystemD(name string, template string, opts options) (Service, error) {
 	logging.Debugf("%s: template: %s", logTagSystemD, template)
 
 	serviceSpec := encoders.SystemDToSERVICE(template)
+	serviceSpec.Name = name
 
 	return &systemdService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: false,
 		fileContentTemplate:    template,
+		opts:                   opts,
 	}, nil
 }
 
 func (thisRef systemdService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef systemdService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// INFO: instances run from the template's unit file, installing the template installed them
+	if len(thisRef.templateName) > 0 {
+		if _, err := os.Stat(thisRef.sourceFilePath()); err != nil {
+			return fmt.Errorf("%w: install the template %s first", ErrServiceDoesNotExist, thisRef.templateName)
+		}
+
+		return nil
+	}
+
 	dir := filepath.Dir(thisRef.filePath())
 
 	// 1.
@@ -80,184 +121,876 @@ func (thisRef systemdService) Install() error {
 	logging.Debugf("generating unit file")
 
 	fileContent := encoders.SERVICEToSystemD(thisRef.serviceSpec)
-
 	if !thisRef.useConfigAsFileContent {
 		fileContent = thisRef.fileContentTemplate
 	}
 
-	logging.Debugf("writing unit to: %s", thisRef.filePath())
+	if thisRef.opts.notify {
+		fileContent = systemdNotifyUnit(fileContent, thisRef.opts.watchdog)
+	}
 
-	err := ioutil.WriteFile(thisRef.filePath(), []byte(fileContent), 0644)
-	if err != nil {
-		return err
+	if thisRef.opts.limits != nil {
+		fileContent = systemdLimitsUnit(fileContent, *thisRef.opts.limits)
+	}
+
+	unitFiles := []systemdUnitFile{{path: thisRef.filePath(), content: fileContent}}
+
+	if thisRef.opts.socket != nil {
+		unitFiles = append(unitFiles, systemdUnitFile{path: thisRef.socketFilePath(), content: systemdSocketUnit(thisRef.serviceSpec, *thisRef.opts.socket)})
+	}
+
+	if thisRef.opts.schedule != nil {
+		unitFiles = append(unitFiles, systemdUnitFile{path: thisRef.timerFilePath(), content: systemdTimerUnit(thisRef.serviceSpec, *thisRef.opts.schedule)})
 	}
 
-	logging.Debugf("wrote unit: %s", fileContent)
+	// 3. all of them or none
+	if thisRef.opts.verify {
+		logging.Debugf("verifying units")
+
+		if err := thisRef.verifyUnits(ctx, unitFiles); err != nil {
+			return err
+		}
+	}
+
+	// 4.
+	for _, unitFile := range unitFiles {
+		logging.Debugf("writing unit to: %s", unitFile.path)
+
+		err := ioutil.WriteFile(unitFile.path, []byte(unitFile.content), 0644)
+		if err != nil {
+			return err
+		}
+
+		logging.Debugf("wrote unit: %s", unitFile.content)
+	}
+
+	// 5. the user manager has to run for the service to start at boot
+	if thisRef.opts.linger {
+		logging.Debugf("enabling linger")
+
+		err := thisRef.enableLinger(ctx)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 func (thisRef systemdService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef systemdService) UninstallContext(ctx context.Context) error {
 	// 1.
 	logging.Debugf("%s: attempting to uninstall: %s", logTagSystemD, thisRef.serviceSpec.Name)
 
-	// 2.
-	err := thisRef.Stop()
-	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
-		return err
+	// 2. a template never runs, only its instances do
+	if !thisRef.opts.isOffline() && !isTemplateName(thisRef.serviceSpec.Name) {
+		err := thisRef.StopContext(ctx)
+		if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+			return err
+		}
 	}
 
 	// 3.
+	if !isTemplateName(thisRef.serviceSpec.Name) {
+		err := thisRef.DisableContext(ctx)
+		if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+			return err
+		}
+	}
+
+	// 4. the unit file belongs to the template, the other instances still need it
+	if len(thisRef.templateName) > 0 {
+		return nil
+	}
+
+	// 5.
+	if thisRef.opts.socket != nil {
+		logging.Debugf("remove socket unit file")
+		err := os.Remove(thisRef.socketFilePath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if thisRef.opts.schedule != nil {
+		logging.Debugf("remove timer unit file")
+		err := os.Remove(thisRef.timerFilePath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// 6. a mask in `<unit dir>.control` would outlive the unit and mask the next one installed with this name
+	err := thisRef.removeControlMasks()
+	if err != nil {
+		return err
+	}
+
+	// 7.
 	logging.Debugf("remove unit file")
 	err = os.Remove(thisRef.filePath())
-	if e, ok := err.(*os.PathError); ok {
-		if os.IsNotExist(e.Err) {
-			return nil
-		}
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
 
-	return err
+	// 8. systemd keeps the removed units loaded until it reloads
+	if thisRef.opts.isOffline() {
+		return nil
+	}
+
+	logging.Debugf("reloading daemon")
+	return thisRef.manager().daemonReload(ctx)
 }
 
 func (thisRef systemdService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef systemdService) StartContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.opts.enableOnStart {
+			return thisRef.EnableContext(ctx)
+		}
+
+		return thisRef.opts.errOffline("start")
+	}
+
 	// 1.
-	logging.Debugf("reloading daemon")
-	output, err := runSystemCtlCommand("daemon-reload")
-	if err != nil {
-		return err
+	if thisRef.opts.enableOnStart {
+		err := thisRef.EnableContext(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		logging.Debugf("reloading daemon")
+		err := thisRef.manager().daemonReload(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	// 2.
-	logging.Debugf("enabling unit file with systemd")
-	output, err = runSystemCtlCommand("enable", thisRef.serviceSpec.Name)
-	if err != nil {
-		if strings.Contains(output, "Failed to enable unit") && strings.Contains(output, "does not exist") {
-			return ErrServiceDoesNotExist
+	logging.Debugf("loading unit file with systemd")
+	return thisRef.manager().startUnit(ctx, thisRef.controlUnit())
+}
+
+func (thisRef systemdService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef systemdService) StopContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.opts.enableOnStart {
+			return thisRef.DisableContext(ctx)
 		}
 
-		return err
+		return thisRef.opts.errOffline("stop")
 	}
 
-	// 3.
-	logging.Debugf("loading unit file with systemd")
-	output, err = runSystemCtlCommand("start", thisRef.serviceSpec.Name)
+	// 1.
+	logging.Debugf("stopping unit file with systemd")
+	err := thisRef.manager().stopUnit(ctx, thisRef.controlUnit())
 	if err != nil {
-		if strings.Contains(output, "Failed to start") && strings.Contains(output, "not found") {
-			return ErrServiceDoesNotExist
+		return err
+	}
+
+	// INFO: stopping the socket or the timer leaves a service they already started running
+	if (thisRef.opts.socket != nil && !thisRef.opts.socket.Accept) || thisRef.opts.schedule != nil {
+		err = thisRef.manager().stopUnit(ctx, thisRef.serviceSpec.Name)
+		if err != nil {
+			return err
 		}
+	}
 
+	if !thisRef.opts.enableOnStart {
+		return nil
+	}
+
+	// 2.
+	err = thisRef.DisableContext(ctx)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	// 3.
+	logging.Debugf("running reset-failed")
+	return thisRef.manager().resetFailed(ctx)
 }
 
-func (thisRef systemdService) Stop() error {
+func (thisRef systemdService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+// RestartContext - restarts what `StartContext()` starts, with `Accept=yes` connections already open keep their instance
+func (thisRef systemdService) RestartContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("restart")
+	}
+
 	// 1.
 	logging.Debugf("reloading daemon")
-	_, err := runSystemCtlCommand("daemon-reload")
+	err := thisRef.manager().daemonReload(ctx)
 	if err != nil {
 		return err
 	}
 
 	// 2.
-	logging.Debugf("stopping unit file with systemd")
-	output, err := runSystemCtlCommand("stop", thisRef.serviceSpec.Name)
+	logging.Debugf("restarting unit file with systemd")
+	err = thisRef.manager().restartUnit(ctx, thisRef.controlUnit())
 	if err != nil {
-		if strings.Contains(output, "Failed to stop") && strings.Contains(output, "not loaded") {
-			return ErrServiceDoesNotExist
-		}
-
 		return err
 	}
 
-	// 3.
-	logging.Debugf("disabling unit file with systemd")
-	output, err = runSystemCtlCommand("disable", thisRef.serviceSpec.Name)
-	if err != nil {
-		logging.Warningf("stopping unit file with systemd")
+	// INFO: restarting the socket leaves a service it already started running the old code, restarting
+	// the timer doesn't run the job, restarting its service would
+	if thisRef.opts.socket != nil && !thisRef.opts.socket.Accept {
+		return thisRef.manager().restartUnit(ctx, thisRef.serviceSpec.Name)
+	}
 
-		if strings.Contains(output, "Failed to disable") && strings.Contains(output, "does not exist") {
-			return ErrServiceDoesNotExist
-		} else if strings.Contains(output, "Removed") {
-			return nil
+	return nil
+}
+
+func (thisRef systemdService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef systemdService) ReloadContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("reload")
+	}
+
+	// INFO: sockets and timers can't reload, with `Accept=yes` there is no single service to reload either
+	if thisRef.opts.socket != nil && thisRef.opts.socket.Accept {
+		return fmt.Errorf("%w: reload: %s runs an instance per connection", ErrServiceUnsupportedRequest, thisRef.serviceSpec.Name)
+	}
+
+	// 1.
+	logging.Debugf("reloading unit file with systemd")
+	return thisRef.manager().reloadUnit(ctx, thisRef.serviceSpec.Name)
+}
+
+func (thisRef systemdService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+func (thisRef systemdService) EnableContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.isMasked() {
+			return errMasked(thisRef.serviceSpec.Name)
 		}
 
-		return err
+		return thisRef.enableOffline()
 	}
 
-	// 4.
+	// 1.
 	logging.Debugf("reloading daemon")
-	_, err = runSystemCtlCommand("daemon-reload")
+	err := thisRef.manager().daemonReload(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 5.
-	logging.Debugf("running reset-failed")
-	_, err = runSystemCtlCommand("reset-failed")
+	// 2.
+	logging.Debugf("enabling unit file with systemd")
+	return thisRef.manager().enableUnit(ctx, thisRef.controlUnit())
+}
+
+func (thisRef systemdService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+func (thisRef systemdService) DisableContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.disableOffline()
+	}
+
+	// 1.
+	logging.Debugf("disabling unit file with systemd")
+	err := thisRef.manager().disableUnit(ctx, thisRef.controlUnit())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// 2.
+	logging.Debugf("reloading daemon")
+	return thisRef.manager().daemonReload(ctx)
+}
+
+func (thisRef systemdService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef systemdService) IsEnabledContext(ctx context.Context) (bool, error) {
+	if thisRef.opts.isOffline() {
+		return thisRef.isEnabledOffline()
+	}
+
+	state, err := thisRef.manager().unitFileState(ctx, thisRef.controlUnit())
+	if err != nil {
+		return false, err
+	}
+
+	switch state {
+	case "enabled", "enabled-runtime", "alias", "static", "indirect", "generated":
+		return true, nil
+	}
+
+	return false, nil
 }
 
 func (thisRef systemdService) Info() Info {
-	fileContent, _ := ioutil.ReadFile(thisRef.filePath())
+	return thisRef.InfoContext(context.Background())
+}
+
+func (thisRef systemdService) InfoContext(ctx context.Context) Info {
+	fileContent, _ := ioutil.ReadFile(thisRef.sourceFilePath())
 
 	result := Info{
 		Error:       nil,
 		Service:     thisRef.serviceSpec,
 		IsRunning:   false,
 		PID:         -1,
-		FilePath:    thisRef.filePath(),
+		FilePath:    thisRef.sourceFilePath(),
 		FileContent: string(fileContent),
+		Status:      Status{State: StateUnknown},
+	}
+
+	if thisRef.opts.isOffline() {
+		result.IsMasked = thisRef.isMasked()
+
+		if result.IsMasked {
+			result.Status.EnabledState = "masked"
+		} else if len(fileContent) <= 0 {
+			result.Error = ErrServiceDoesNotExist
+		} else if isEnabled, err := thisRef.isEnabledOffline(); err == nil {
+			result.Status.EnabledState = enabledStateAsString(isEnabled)
+		}
+
+		if dropIns, err := thisRef.ListDropInsContext(ctx); err == nil {
+			for _, dropIn := range dropIns {
+				result.DropIns = append(result.DropIns, dropIn.FilePath)
+			}
+		}
+
+		return result
 	}
 
-	output, err := runSystemCtlCommand("status", thisRef.serviceSpec.Name)
+	// INFO: with `Accept=yes` there is no single service to describe, only the socket
+	describedUnit := thisRef.serviceSpec.Name
+	if thisRef.opts.socket != nil && thisRef.opts.socket.Accept {
+		describedUnit = thisRef.controlUnit()
+	}
+
+	properties, err := thisRef.manager().unitProperties(ctx, describedUnit, systemdInfoProperties)
 	if err != nil {
 		result.Error = err
 		return result
 	}
 
-	if strings.Contains(output, "could not be found") {
+	if properties.String("LoadState") == "not-found" {
 		result.Error = ErrServiceDoesNotExist
 		return result
 	}
 
-	for _, line := range strings.Split(output, "\n") {
-		if strings.Contains(line, "Main PID") {
-			lineParts := strings.Split(strings.TrimSpace(line), " ")
-			if len(lineParts) >= 2 {
-				result.PID, _ = strconv.Atoi(lineParts[2])
-			}
-		} else if strings.Contains(line, "Active") {
-			if strings.Contains(line, "active (running)") {
-				result.IsRunning = true
-			}
+	properties.applyTo(&result)
+	result.IsMasked = properties.String("LoadState") == "masked" || strings.HasPrefix(result.Status.EnabledState, "masked")
+
+	// INFO: the trigger times are on the timer, not on the service
+	if thisRef.opts.schedule != nil {
+		timerProperties, err := thisRef.manager().unitProperties(ctx, thisRef.serviceSpec.Name+".timer", systemdTimerProperties)
+		if err == nil {
+			result.Status.LastTrigger = timerProperties.Time("LastTriggerUSec")
+			result.Status.NextTrigger = timerProperties.Time("NextElapseUSecRealtime")
 		}
 	}
 
+	// report the unit file systemd actually loaded, it may not be the one we would install to
+	if fragmentPath := properties.String("FragmentPath"); len(fragmentPath) > 0 && fragmentPath != result.FilePath {
+		result.FilePath = fragmentPath
+		fileContent, _ = ioutil.ReadFile(fragmentPath)
+		result.FileContent = string(fileContent)
+	}
+
 	return result
 }
 
+// Instance - `systemctl` starts `<template><instance>` from the template's unit file with `%i` set to the instance
+func (thisRef systemdService) Instance(instance string) (Service, error) {
+	name, err := instanceName(thisRef.serviceSpec.Name, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceSpec := thisRef.serviceSpec
+	serviceSpec.Name = name
+
+	return &systemdService{
+		serviceSpec:            serviceSpec,
+		useConfigAsFileContent: thisRef.useConfigAsFileContent,
+		fileContentTemplate:    thisRef.fileContentTemplate,
+		opts:                   thisRef.opts,
+		templateName:           thisRef.serviceSpec.Name,
+		loadedFrom:             thisRef.loadedFrom,
+	}, nil
+}
+
+// manager - how this service talks to systemd, `systemctl` unless `WithSystemdDBus()` was given
+func (thisRef systemdService) manager() systemdManager {
+	if thisRef.opts.systemdDBus {
+		return systemdDBusManager{opts: thisRef.opts}
+	}
+
+	return systemctlManager{opts: thisRef.opts}
+}
+
+// unitDir - where units are installed, as seen by the init system
+func (thisRef systemdService) unitDir() string {
+	if !thisRef.opts.isUserScope() {
+		return "/etc/systemd/system"
+	}
+
+	return systemdUserConfigDir()
+}
+
 func (thisRef systemdService) filePath() string {
-	if helpers.IsRoot() {
-		return filepath.Join("/etc/systemd/system", thisRef.serviceSpec.Name+".service")
+	if len(thisRef.templateName) > 0 {
+		return thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), thisRef.templateName+".service"))
+	}
+
+	return thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), thisRef.serviceUnitName()))
+}
+
+// serviceUnitName - ex: `nginx.service`, `Accept=yes` starts `<name>@<connection>.service` instances from a template
+func (thisRef systemdService) serviceUnitName() string {
+	if thisRef.opts.socket != nil && thisRef.opts.socket.Accept {
+		return thisRef.serviceSpec.Name + "@.service"
 	}
 
-	return filepath.Join(helpers.HomeDir(""), ".config/systemd/user", thisRef.serviceSpec.Name+".service")
+	return thisRef.serviceSpec.Name + ".service"
+}
+
+// sourceFilePath - the unit file the service was loaded from, `filePath()` for the ones built here
+func (thisRef systemdService) sourceFilePath() string {
+	if len(thisRef.loadedFrom) > 0 {
+		return thisRef.loadedFrom
+	}
+
+	return thisRef.filePath()
+}
+
+func (thisRef systemdService) socketFilePath() string {
+	return thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), thisRef.serviceSpec.Name+".socket"))
 }
 
-func runSystemCtlCommand(args ...string) (string, error) {
-	if !helpers.IsRoot() {
+func (thisRef systemdService) timerFilePath() string {
+	return thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), thisRef.serviceSpec.Name+".timer"))
+}
+
+// controlUnit - what Start, Stop, Enable and Disable act on, the socket for socket-activated services
+// and the timer for scheduled ones
+func (thisRef systemdService) controlUnit() string {
+	if thisRef.opts.socket != nil {
+		return thisRef.serviceSpec.Name + ".socket"
+	}
+
+	if thisRef.opts.schedule != nil {
+		return thisRef.serviceSpec.Name + ".timer"
+	}
+
+	return thisRef.serviceSpec.Name
+}
+
+// enableFilePath - the unit whose `[Install]` section enabling follows
+func (thisRef systemdService) enableFilePath() string {
+	if thisRef.opts.socket != nil {
+		return thisRef.socketFilePath()
+	}
+
+	if thisRef.opts.schedule != nil {
+		return thisRef.timerFilePath()
+	}
+
+	return thisRef.filePath()
+}
+
+// enableUnitName - the unit the `[Install]` links are named after, instances link to the template's file under their own name
+func (thisRef systemdService) enableUnitName() string {
+	if len(thisRef.templateName) > 0 && thisRef.opts.socket == nil && thisRef.opts.schedule == nil {
+		return thisRef.serviceSpec.Name + ".service"
+	}
+
+	return filepath.Base(thisRef.enableFilePath())
+}
+
+// enableOffline - does by hand what `systemctl enable` does, creates the links the `[Install]` section asks for
+func (thisRef systemdService) enableOffline() error {
+	fileContent, err := ioutil.ReadFile(thisRef.enableFilePath())
+	if err != nil {
+		return ErrServiceDoesNotExist
+	}
+
+	unitName := thisRef.enableUnitName()
+	unitPath := filepath.Join(thisRef.unitDir(), filepath.Base(thisRef.enableFilePath()))
+
+	for _, link := range systemdInstallLinks(unitName, string(fileContent)) {
+		linkPath := thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), link))
+		if _, err := os.Lstat(linkPath); err == nil {
+			continue
+		}
+
+		logging.Debugf("%s: linking %s -> %s", logTagSystemD, linkPath, unitPath)
+
+		os.MkdirAll(filepath.Dir(linkPath), os.ModePerm)
+		err = os.Symlink(unitPath, linkPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disableOffline - does by hand what `systemctl disable` does
+func (thisRef systemdService) disableOffline() error {
+	unitName := thisRef.enableUnitName()
+	links := []string{}
+
+	if fileContent, err := ioutil.ReadFile(thisRef.enableFilePath()); err == nil {
+		for _, link := range systemdInstallLinks(unitName, string(fileContent)) {
+			links = append(links, thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), link)))
+		}
+	}
+
+	// the `[Install]` section may have changed since the unit was enabled
+	for _, pattern := range [...]string{"*.wants", "*.requires"} {
+		matches, _ := filepath.Glob(filepath.Join(thisRef.opts.rooted(thisRef.unitDir()), pattern, unitName))
+		links = append(links, matches...)
+	}
+
+	for _, link := range links {
+		logging.Debugf("%s: removing link %s", logTagSystemD, link)
+
+		err := os.Remove(link)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (thisRef systemdService) isEnabledOffline() (bool, error) {
+	fileContent, err := ioutil.ReadFile(thisRef.enableFilePath())
+	if err != nil {
+		return false, ErrServiceDoesNotExist
+	}
+
+	unitName := thisRef.enableUnitName()
+
+	links := systemdInstallLinks(unitName, string(fileContent))
+	if len(links) <= 0 {
+		// no `[Install]` section, systemd calls this static and it counts as enabled
+		return true, nil
+	}
+
+	for _, link := range links {
+		if _, err := os.Lstat(thisRef.opts.rooted(filepath.Join(thisRef.unitDir(), link))); err == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// systemdInstallLinks - the links, relative to the unit folder, that `WantedBy=`, `RequiredBy=` and `Alias=` ask for
+func systemdInstallLinks(unitName string, unitContent string) []string {
+	links := []string{}
+	section := ""
+
+	for _, line := range strings.Split(unitContent, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line
+			continue
+		}
+
+		keyValue := strings.SplitN(line, "=", 2)
+		if section != "[Install]" || len(keyValue) != 2 {
+			continue
+		}
+
+		for _, value := range strings.Fields(keyValue[1]) {
+			switch strings.TrimSpace(keyValue[0]) {
+			case "WantedBy":
+				links = append(links, filepath.Join(value+".wants", unitName))
+			case "RequiredBy":
+				links = append(links, filepath.Join(value+".requires", unitName))
+			case "Alias":
+				links = append(links, value)
+			}
+		}
+	}
+
+	return links
+}
+
+// systemdSocketUnit - the encoder only knows services, socket units are simple enough to write here
+func systemdSocketUnit(serviceSpec spec.SERVICE, socket Socket) string {
+	sb := strings.Builder{}
+
+	sb.WriteString("[Unit]\n")
+	if len(serviceSpec.Description) > 0 {
+		sb.WriteString(fmt.Sprintf("Description=%s socket\n", serviceSpec.Description))
+	}
+
+	sb.WriteString("\n[Socket]\n")
+	for _, address := range socket.ListenStream {
+		sb.WriteString(fmt.Sprintf("ListenStream=%s\n", address))
+	}
+	for _, address := range socket.ListenDatagram {
+		sb.WriteString(fmt.Sprintf("ListenDatagram=%s\n", address))
+	}
+	if socket.Accept {
+		sb.WriteString("Accept=yes\n")
+	}
+	if len(socket.FileDescriptorName) > 0 {
+		sb.WriteString(fmt.Sprintf("FileDescriptorName=%s\n", socket.FileDescriptorName))
+	}
+
+	sb.WriteString("\n[Install]\n")
+	sb.WriteString("WantedBy=sockets.target\n")
+
+	return sb.String()
+}
+
+// stateFromActiveState - maps systemd's `ActiveState=` to State
+func stateFromActiveState(activeState string) State {
+	switch activeState {
+	case "active", "reloading":
+		return StateActive
+	case "inactive":
+		return StateInactive
+	case "activating":
+		return StateActivating
+	case "deactivating":
+		return StateDeactivating
+	case "failed":
+		return StateFailed
+	}
+
+	return StateUnknown
+}
+
+// systemdManager - the calls the systemd backend makes into systemd, by running `systemctl` or over D-Bus.
+// Unit names without a suffix are services.
+type systemdManager interface {
+	daemonReload(ctx context.Context) error
+	startUnit(ctx context.Context, name string) error
+	stopUnit(ctx context.Context, name string) error
+	restartUnit(ctx context.Context, name string) error
+	reloadUnit(ctx context.Context, name string) error
+	enableUnit(ctx context.Context, name string) error
+	disableUnit(ctx context.Context, name string) error
+	maskUnit(ctx context.Context, name string) error
+	unmaskUnit(ctx context.Context, name string) error
+	resetFailed(ctx context.Context) error
+	unitFileState(ctx context.Context, name string) (string, error)                             // ex: enabled, disabled, static
+	unitProperties(ctx context.Context, name string, names []string) (systemdProperties, error) // what `systemctl show` prints
+	startTransientUnit(ctx context.Context, serviceSpec spec.SERVICE) error                     // runs the service without a unit file
+}
+
+// systemctlManager - talks to systemd by running `systemctl` through the Executor
+type systemctlManager struct {
+	opts options
+}
+
+func (thisRef systemctlManager) daemonReload(ctx context.Context) error {
+	_, err := thisRef.run(ctx, "daemon-reload")
+	return err
+}
+
+func (thisRef systemctlManager) startUnit(ctx context.Context, name string) error {
+	output, err := thisRef.run(ctx, "start", name)
+	if err != nil && strings.Contains(output, "Failed to start") && strings.Contains(output, "not found") {
+		return reclassify(err, ErrServiceDoesNotExist)
+	}
+
+	return err
+}
+
+func (thisRef systemctlManager) stopUnit(ctx context.Context, name string) error {
+	output, err := thisRef.run(ctx, "stop", name)
+	if err != nil && strings.Contains(output, "Failed to stop") && strings.Contains(output, "not loaded") {
+		return reclassify(err, ErrServiceDoesNotExist)
+	}
+
+	return err
+}
+
+func (thisRef systemctlManager) restartUnit(ctx context.Context, name string) error {
+	output, err := thisRef.run(ctx, "restart", name)
+	if err != nil && strings.Contains(output, "Failed to restart") && strings.Contains(output, "not found") {
+		return reclassify(err, ErrServiceDoesNotExist)
+	}
+
+	return err
+}
+
+func (thisRef systemctlManager) reloadUnit(ctx context.Context, name string) error {
+	output, err := thisRef.run(ctx, "reload", name)
+	if err != nil {
+		if strings.Contains(output, "Failed to reload") && strings.Contains(output, "not found") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		} else if strings.Contains(output, "not applicable") {
+			return reclassify(err, ErrServiceUnsupportedRequest)
+		}
+	}
+
+	return err
+}
+
+func (thisRef systemctlManager) enableUnit(ctx context.Context, name string) error {
+	output, err := thisRef.run(ctx, "enable", name)
+	if err != nil && strings.Contains(output, "Failed to enable unit") && strings.Contains(output, "does not exist") {
+		return reclassify(err, ErrServiceDoesNotExist)
+	}
+
+	return err
+}
+
+func (thisRef systemctlManager) maskUnit(ctx context.Context, name string) error {
+	_, err := thisRef.run(ctx, "mask", name)
+	return err
+}
+
+func (thisRef systemctlManager) unmaskUnit(ctx context.Context, name string) error {
+	_, err := thisRef.run(ctx, "unmask", name)
+	return err
+}
+
+func (thisRef systemctlManager) disableUnit(ctx context.Context, name string) error {
+	output, err := thisRef.run(ctx, "disable", name)
+	if err != nil {
+		if strings.Contains(output, "Failed to disable") && strings.Contains(output, "does not exist") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		} else if strings.Contains(output, "Removed") {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (thisRef systemctlManager) resetFailed(ctx context.Context) error {
+	_, err := thisRef.run(ctx, "reset-failed")
+	return err
+}
+
+func (thisRef systemctlManager) unitFileState(ctx context.Context, name string) (string, error) {
+	// INFO: `is-enabled` exits with non-zero for anything that is not enabled, the output tells the state
+	output, err := thisRef.run(ctx, "is-enabled", name)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	state := strings.TrimSpace(output)
+	switch state {
+	case "enabled", "enabled-runtime", "alias", "static", "indirect", "generated",
+		"disabled", "linked", "linked-runtime", "masked", "masked-runtime", "transient":
+		return state, nil
+	}
+
+	if strings.Contains(output, "No such file or directory") || strings.Contains(output, "not found") {
+		return "", ErrServiceDoesNotExist
+	}
+
+	return "", err
+}
+
+// unitProperties - timestamps as `@<seconds>`, the text form names the zone with an abbreviation that can't be parsed
+// reliably, systemd older than 247 does not know `--timestamp=` and prints that form
+func (thisRef systemctlManager) unitProperties(ctx context.Context, name string, names []string) (systemdProperties, error) {
+	output, err := thisRef.run(ctx, "show", "--timestamp=unix", "--property="+strings.Join(names, ","), name)
+	if err != nil && ctx.Err() == nil && strings.Contains(output, "--timestamp") {
+		output, err = thisRef.run(ctx, "show", "--property="+strings.Join(names, ","), name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSystemCtlShow(output), nil
+}
+
+// startTransientUnit - `systemd-run`, `--collect` unloads the unit once it exits, even if it failed
+func (thisRef systemctlManager) startTransientUnit(ctx context.Context, serviceSpec spec.SERVICE) error {
+	args := []string{"--unit", systemdUnitName(serviceSpec.Name), "--collect"}
+	if thisRef.opts.isUserScope() {
 		args = append([]string{"--user"}, args...)
 	}
+	if len(serviceSpec.Description) > 0 {
+		args = append(args, "--description", serviceSpec.Description)
+	}
+	if len(serviceSpec.WorkingDirectory) > 0 {
+		args = append(args, "--property", "WorkingDirectory="+serviceSpec.WorkingDirectory)
+	}
+	for _, variable := range systemdEnvironment(serviceSpec.Environment) {
+		args = append(args, "--setenv", variable)
+	}
+	if thisRef.opts.notify {
+		for _, setting := range systemdNotifySettings(thisRef.opts.watchdog) {
+			args = append(args, "--property", setting)
+		}
+	}
+	if thisRef.opts.limits != nil {
+		for _, setting := range systemdLimitSettings(*thisRef.opts.limits) {
+			args = append(args, "--property", setting)
+		}
+	}
+	if len(serviceSpec.Credentials.User) > 0 {
+		args = append(args, "--uid", serviceSpec.Credentials.User)
+	}
+	if len(serviceSpec.Credentials.Group) > 0 {
+		args = append(args, "--gid", serviceSpec.Credentials.Group)
+	}
+	args = append(args, "--", serviceSpec.Executable)
+	args = append(args, serviceSpec.Args...)
+
+	logging.Debugf("%s: RUN-SYSTEMD-RUN: systemd-run %s", logTagSystemD, strings.Join(args, " "))
+
+	name, execArgs := "systemd-run", args
+	if thisRef.opts.isUserScope() {
+		name, execArgs = userManagerCommand(name, args)
+	}
+
+	output, err := thisRef.opts.executor.Exec(ctx, name, execArgs...)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	logging.Debugf("%s: RUN-SYSTEMD-RUN-OUT: output: %s", logTagSystemD, output)
+
+	if err != nil && thisRef.opts.isUserScope() && isUserManagerUnreachable(output) {
+		return errUserManagerUnreachable(newOperationError(string(spec.InitSystemd), "run", append([]string{"systemd-run"}, args...), output, err))
+	}
+
+	return newOperationError(string(spec.InitSystemd), "run", append([]string{"systemd-run"}, args...), output, err)
+}
+
+func (thisRef systemctlManager) run(ctx context.Context, args ...string) (string, error) {
+	name, execArgs := "systemctl", args
+	if thisRef.opts.isUserScope() {
+		args = append([]string{"--user"}, args...)
+		name, execArgs = userManagerCommand(name, args)
+	}
 
 	logging.Debugf("%s: RUN-SYSTEMCTL: systemctl %s", logTagSystemD, strings.Join(args, " "))
 
-	output, err := helpers.ExecWithArgs("systemctl", args...)
+	output, err := thisRef.opts.executor.Exec(ctx, name, execArgs...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	errAsString := ""
 	if err != nil {
 		errAsString = err.Error()
@@ -265,5 +998,120 @@ func runSystemCtlCommand(args ...string) (string, error) {
 
 	logging.Debugf("%s: RUN-SYSTEMCTL-OUT: output: %s, error: %s", logTagSystemD, output, errAsString)
 
-	return output, err
+	if err != nil && thisRef.opts.isUserScope() && isUserManagerUnreachable(output) {
+		return output, errUserManagerUnreachable(newOperationError(string(spec.InitSystemd), operationFromArgs(args), append([]string{"systemctl"}, args...), output, err))
+	}
+
+	return output, newOperationError(string(spec.InitSystemd), operationFromArgs(args), append([]string{"systemctl"}, args...), output, err)
+}
+
+// systemdEnvironment - `KEY=value` pairs, sorted so the same environment always gives the same command line
+func systemdEnvironment(environment map[string]string) []string {
+	result := []string{}
+	for key, value := range environment {
+		result = append(result, key+"="+value)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// systemdNotifySettings - `Type=notify` with an optional watchdog as `[Service]` settings, `systemd-run --property` takes the same
+func systemdNotifySettings(watchdog time.Duration) []string {
+	result := []string{"Type=notify"}
+	if watchdog > 0 && watchdog%time.Second == 0 {
+		result = append(result, fmt.Sprintf("WatchdogSec=%d", watchdog/time.Second))
+	} else if watchdog > 0 {
+		result = append(result, fmt.Sprintf("WatchdogSec=%dms", watchdog/time.Millisecond))
+	}
+
+	return result
+}
+
+// systemdNotifyUnit - the encoder always writes `Type=simple`, this makes it `Type=notify` with an optional watchdog
+func systemdNotifyUnit(unitContent string, watchdog time.Duration) string {
+	serviceSettings := strings.Join(systemdNotifySettings(watchdog), "\n") + "\n"
+
+	lines := []string{}
+	for _, line := range strings.SplitAfter(unitContent, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "Type=") {
+			continue
+		}
+
+		lines = append(lines, line)
+		if strings.TrimSpace(line) == "[Service]" {
+			lines = append(lines, serviceSettings)
+		}
+	}
+
+	return strings.Join(lines, "")
+}
+
+// systemdLimitSettings - Limits as `[Service]` settings, `systemd-run --property` takes the same
+func systemdLimitSettings(limits Limits) []string {
+	result := []string{}
+	if limits.MemoryMax > 0 {
+		result = append(result, fmt.Sprintf("MemoryMax=%d", limits.MemoryMax))
+	}
+	if limits.CPUQuota > 0 {
+		result = append(result, fmt.Sprintf("CPUQuota=%d%%", limits.CPUQuota))
+	}
+	if limits.TasksMax > 0 {
+		result = append(result, fmt.Sprintf("TasksMax=%d", limits.TasksMax))
+	}
+	if limits.OpenFiles > 0 {
+		result = append(result, fmt.Sprintf("LimitNOFILE=%d", limits.OpenFiles))
+	}
+
+	return result
+}
+
+// systemdLimitsUnit - adds the limits to the `[Service]` section, the same settings already there are dropped
+func systemdLimitsUnit(unitContent string, limits Limits) string {
+	settings := systemdLimitSettings(limits)
+
+	keys := map[string]bool{}
+	for _, setting := range settings {
+		keys[strings.SplitN(setting, "=", 2)[0]] = true
+	}
+
+	lines := []string{}
+	for _, line := range strings.SplitAfter(unitContent, "\n") {
+		if key := strings.SplitN(strings.TrimSpace(line), "=", 2)[0]; keys[key] {
+			continue
+		}
+
+		lines = append(lines, line)
+		if strings.TrimSpace(line) == "[Service]" {
+			lines = append(lines, strings.Join(settings, "\n")+"\n")
+		}
+	}
+
+	return strings.Join(lines, "")
+}
+
+// systemdTimerUnit - starts `<name>.service` on the schedule, a missed run is caught up on boot
+func systemdTimerUnit(serviceSpec spec.SERVICE, schedule Schedule) string {
+	sb := strings.Builder{}
+
+	sb.WriteString("[Unit]\n")
+	if len(serviceSpec.Description) > 0 {
+		sb.WriteString(fmt.Sprintf("Description=%s timer\n", serviceSpec.Description))
+	}
+
+	sb.WriteString("\n[Timer]\n")
+	if onCalendar := schedule.onCalendar(); len(onCalendar) > 0 {
+		sb.WriteString(fmt.Sprintf("OnCalendar=%s\n", onCalendar))
+		sb.WriteString("Persistent=true\n")
+	} else {
+		// INFO: intervals the calendar can't express count from when the timer and the service last ran
+		seconds := int64(schedule.Every / time.Second)
+		sb.WriteString(fmt.Sprintf("OnActiveSec=%ds\n", seconds))
+		sb.WriteString(fmt.Sprintf("OnUnitActiveSec=%ds\n", seconds))
+	}
+
+	sb.WriteString("\n[Install]\n")
+	sb.WriteString("WantedBy=timers.target\n")
+
+	return sb.String()
 }
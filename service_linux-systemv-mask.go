@@ -0,0 +1,87 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	logging "github.com/codemodify/systemkit-logging"
+)
+
+// systemvMaskedScript - what runs in place of a masked init script, `status` says not running as LSB asks
+const systemvMaskedScript = `#!/bin/sh
+# masked, Unmask() puts the original back from %s
+case "$1" in
+	status) exit 3 ;;
+	stop) exit 0 ;;
+	*) echo "%s is masked" >&2; exit 1 ;;
+esac
+`
+
+func (thisRef systemvService) Mask() error {
+	return thisRef.MaskContext(context.Background())
+}
+
+// MaskContext - removes the rc links and moves the init script aside, a script that refuses to start takes its place
+func (thisRef systemvService) MaskContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.isMasked() {
+		return nil
+	}
+
+	if _, err := os.Stat(thisRef.filePath()); err != nil {
+		return ErrServiceDoesNotExist
+	}
+
+	// 1.
+	err := thisRef.DisableContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 2.
+	logging.Debugf("%s: moving %s aside to: %s", logTagSystemV, thisRef.filePath(), thisRef.maskedFilePath())
+	err = os.Rename(thisRef.filePath(), thisRef.maskedFilePath())
+	if err != nil {
+		return err
+	}
+
+	// 3.
+	maskedScript := fmt.Sprintf(systemvMaskedScript, filepath.Join(filepath.Dir(thisRef.scriptPath()), filepath.Base(thisRef.maskedFilePath())), thisRef.serviceSpec.Name)
+	return ioutil.WriteFile(thisRef.filePath(), []byte(maskedScript), 0755)
+}
+
+func (thisRef systemvService) Unmask() error {
+	return thisRef.UnmaskContext(context.Background())
+}
+
+// UnmaskContext - puts the init script back, the rc links are not, `EnableContext()` does that
+func (thisRef systemvService) UnmaskContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if !thisRef.isMasked() {
+		return nil
+	}
+
+	logging.Debugf("%s: restoring %s from: %s", logTagSystemV, thisRef.filePath(), thisRef.maskedFilePath())
+	return os.Rename(thisRef.maskedFilePath(), thisRef.filePath())
+}
+
+func (thisRef systemvService) isMasked() bool {
+	_, err := os.Stat(thisRef.maskedFilePath())
+	return err == nil
+}
+
+// maskedFilePath - where a masked init script waits, a hidden file as `insserv` and `update-rc.d` skip those
+func (thisRef systemvService) maskedFilePath() string {
+	return filepath.Join(filepath.Dir(thisRef.filePath()), "."+thisRef.serviceSpec.Name+".masked")
+}
@@ -3,10 +3,15 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	logging "github.com/codemodify/systemkit-logging"
 	encoders "github.com/codemodify/systemkit-service-encoders-systemv"
@@ -20,41 +25,54 @@ type systemvService struct {
 	serviceSpec            spec.SERVICE
 	useConfigAsFileContent bool
 	fileContentTemplate    string
+	opts                   options
+	instance               string // of an emulated template, exported as `$INSTANCE`
 }
 
-func newServiceFromSERVICE_SystemV(serviceSpec spec.SERVICE) Service {
+func newServiceFromSERVICE_SystemV(serviceSpec spec.SERVICE, opts options) Service {
 	logging.Debugf("%s: serviceSpec object: %s", logTagSystemV, helpers.AsJSONString(serviceSpec))
 
 	return &systemvService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: true,
+		opts:                   opts,
 	}
 }
 
-func newServiceFromName_SystemV(name string) (Service, error) {
-	serviceFile := filepath.Join("/etc/init.d/", name)
+func newServiceFromName_SystemV(name string, opts options) (Service, error) {
+	serviceFile := opts.rooted(filepath.Join("/etc/init.d/", name))
 
 	fileContent, err := ioutil.ReadFile(serviceFile)
 	if err != nil {
 		return nil, ErrServiceDoesNotExist
 	}
 
-	return newServiceFromPlatformTemplate_SystemV(name, string(fileContent))
+	return newServiceFromPlatformTemplate_SystemV(name, string(fileContent), opts)
 }
 
-func newServiceFromPlatformTemplate_SystemV(name string, template string) (Service, error) {
+func newServiceFromPlatformTemplate_SystemV(name string, template string, opts options) (Service, error) {
 	logging.Debugf("%s: template: %s", logTagSystemV, template)
 
 	serviceSpec := encoders.SystemVToSERVICE(template)
+	serviceSpec.Name = name
 
 	return &systemvService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: false,
 		fileContentTemplate:    template,
+		opts:                   opts,
 	}, nil
 }
 
 func (thisRef systemvService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef systemvService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
 	dir := filepath.Dir(thisRef.filePath())
 
 	// 1.
@@ -70,42 +88,62 @@ func (thisRef systemvService) Install() error {
 		fileContent = thisRef.fileContentTemplate
 	}
 
-	logging.Debugf("writing unit to: %s", thisRef.filePath())
+	if thisRef.opts.limits != nil {
+		fileContent = systemvLimitsScript(fileContent, *thisRef.opts.limits)
+	}
 
-	err := ioutil.WriteFile(thisRef.filePath(), []byte(fileContent), 0755)
-	if err != nil {
-		return err
+	if len(thisRef.instance) > 0 {
+		fileContent = systemvScriptPrologue(fileContent, "# instance\n"+instanceEnvVar+"="+shellQuote(thisRef.instance)+"\nexport "+instanceEnvVar+"\n")
 	}
 
-	// additional rc.d magic
-	for _, i := range [...]string{"2", "3", "4", "5"} {
-		if err = os.Symlink(thisRef.filePath(), "/etc/rc"+i+".d/S50"+thisRef.serviceSpec.Name); err != nil {
-			continue
-		}
+	// INFO: a masked service stays masked, the new script is what `UnmaskContext()` puts back
+	filePath := thisRef.filePath()
+	if thisRef.isMasked() {
+		filePath = thisRef.maskedFilePath()
 	}
-	for _, i := range [...]string{"0", "1", "6"} {
-		if err = os.Symlink(thisRef.filePath(), "/etc/rc"+i+".d/K02"+thisRef.serviceSpec.Name); err != nil {
-			continue
-		}
+
+	logging.Debugf("writing unit to: %s", filePath)
+
+	err := ioutil.WriteFile(filePath, []byte(fileContent), 0755)
+	if err != nil {
+		return err
 	}
 
 	logging.Debugf("wrote unit: %s", fileContent)
 
-	return nil
+	// 3.
+	if thisRef.isMasked() {
+		return nil
+	}
+
+	return thisRef.EnableContext(ctx)
 }
 
 func (thisRef systemvService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef systemvService) UninstallContext(ctx context.Context) error {
 	// 1.
 	logging.Debugf("%s: attempting to uninstall: %s", logTagSystemV, thisRef.serviceSpec.Name)
 
 	// 2.
-	err := thisRef.Stop()
-	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
-		return err
+	if !thisRef.opts.isOffline() {
+		err := thisRef.StopContext(ctx)
+		if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+			return err
+		}
 	}
 
 	// 3.
+	err := thisRef.DisableContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 4.
 	logging.Debugf("remove unit file")
+	os.Remove(thisRef.maskedFilePath())
 	err = os.Remove(thisRef.filePath())
 	if e, ok := err.(*os.PathError); ok {
 		if os.IsNotExist(e.Err) {
@@ -117,12 +155,32 @@ func (thisRef systemvService) Uninstall() error {
 }
 
 func (thisRef systemvService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef systemvService) StartContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.opts.enableOnStart {
+			return thisRef.EnableContext(ctx)
+		}
+
+		return thisRef.opts.errOffline("start")
+	}
+
 	// 1.
-	logging.Debugf("loading unit file with systemd")
-	output, err := runServiceCommand(thisRef.serviceSpec.Name, "start")
+	if thisRef.opts.enableOnStart {
+		err := thisRef.EnableContext(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2.
+	logging.Debugf("starting service")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "start")
 	if err != nil {
 		if strings.Contains(output, "Failed to start") && strings.Contains(output, "not found") {
-			return ErrServiceDoesNotExist
+			return reclassify(err, ErrServiceDoesNotExist)
 		}
 
 		return err
@@ -132,12 +190,52 @@ func (thisRef systemvService) Start() error {
 }
 
 func (thisRef systemvService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef systemvService) StopContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.opts.enableOnStart {
+			return thisRef.DisableContext(ctx)
+		}
+
+		return thisRef.opts.errOffline("stop")
+	}
+
 	// 1.
 	logging.Debugf("stopping service")
-	output, err := runServiceCommand(thisRef.serviceSpec.Name, "stop")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "stop")
 	if err != nil {
 		if strings.Contains(output, "Failed to stop") && strings.Contains(output, "not loaded") {
-			return ErrServiceDoesNotExist
+			return reclassify(err, ErrServiceDoesNotExist)
+		}
+
+		return err
+	}
+
+	// 2.
+	if thisRef.opts.enableOnStart {
+		return thisRef.DisableContext(ctx)
+	}
+
+	return nil
+}
+
+func (thisRef systemvService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef systemvService) RestartContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("restart")
+	}
+
+	// 1.
+	logging.Debugf("restarting service")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "restart")
+	if err != nil {
+		if strings.Contains(output, "unrecognized service") {
+			return reclassify(err, ErrServiceDoesNotExist)
 		}
 
 		return err
@@ -146,7 +244,118 @@ func (thisRef systemvService) Stop() error {
 	return nil
 }
 
+func (thisRef systemvService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef systemvService) ReloadContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("reload")
+	}
+
+	// 1.
+	logging.Debugf("reloading service")
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "reload")
+	if err != nil {
+		if strings.Contains(output, "unrecognized service") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		} else if strings.Contains(output, "Usage:") {
+			// the init script has no `reload` action
+			return reclassify(err, ErrServiceUnsupportedRequest)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef systemvService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+func (thisRef systemvService) EnableContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.isMasked() {
+		return errMasked(thisRef.serviceSpec.Name)
+	}
+
+	// 1.
+	logging.Debugf("%s: creating rc.d links for: %s", logTagSystemV, thisRef.serviceSpec.Name)
+	for _, link := range thisRef.rcLinks() {
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+
+		// INFO: a fresh image under `WithRoot()` may not have the runlevel folders yet
+		err := os.MkdirAll(filepath.Dir(link), os.ModePerm)
+		if err == nil {
+			err = os.Symlink(thisRef.scriptPath(), link)
+		}
+		if err != nil {
+			operationErr := newOperationError(string(spec.InitSystemV), "enable", []string{"ln", "-s", thisRef.scriptPath(), link}, "", err)
+			if os.IsPermission(err) {
+				return reclassify(operationErr, ErrServicePermissionDenied)
+			}
+
+			return operationErr
+		}
+	}
+
+	return nil
+}
+
+func (thisRef systemvService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+func (thisRef systemvService) DisableContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 1.
+	logging.Debugf("%s: removing rc.d links for: %s", logTagSystemV, thisRef.serviceSpec.Name)
+	for _, link := range thisRef.existingRCLinks() {
+		err := os.Remove(link)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (thisRef systemvService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef systemvService) IsEnabledContext(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if _, err := os.Stat(thisRef.filePath()); os.IsNotExist(err) {
+		return false, ErrServiceDoesNotExist
+	}
+
+	for _, link := range thisRef.existingRCLinks() {
+		if strings.HasPrefix(filepath.Base(link), "S") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func (thisRef systemvService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+func (thisRef systemvService) InfoContext(ctx context.Context) Info {
 	fileContent, _ := ioutil.ReadFile(thisRef.filePath())
 
 	result := Info{
@@ -156,47 +365,212 @@ func (thisRef systemvService) Info() Info {
 		PID:         -1,
 		FilePath:    thisRef.filePath(),
 		FileContent: string(fileContent),
+		Status:      Status{State: StateUnknown},
+	}
+	result.IsMasked = thisRef.isMasked()
+
+	if len(fileContent) <= 0 {
+		result.Error = ErrServiceDoesNotExist
+		return result
 	}
 
-	// output, err := runServiceCommand("status", thisRef.serviceSpec.Name)
-	// if err != nil {
-	// 	result.Error = err
-	// 	return result
-	// }
-
-	// if strings.Contains(output, "could not be found") {
-	// 	result.Error = ErrServiceDoesNotExist
-	// 	return result
-	// }
-
-	// for _, line := range strings.Split(output, "\n") {
-	// 	if strings.Contains(line, "Main PID") {
-	// 		lineParts := strings.Split(strings.TrimSpace(line), " ")
-	// 		if len(lineParts) >= 2 {
-	// 			result.PID, _ = strconv.Atoi(lineParts[2])
-	// 		}
-	// 	} else if strings.Contains(line, "Active") {
-	// 		if strings.Contains(line, "active (running)") {
-	// 			result.IsRunning = true
-	// 		}
-	// 	}
-	// }
+	if thisRef.opts.isOffline() {
+		if isEnabled, err := thisRef.IsEnabledContext(ctx); err == nil {
+			result.Status.EnabledState = maskedOrEnabledState(result.IsMasked, isEnabled)
+		}
+
+		return result
+	}
+
+	// INFO: LSB init scripts report through the exit code of `status`
+	output, err := thisRef.runServiceCommand(ctx, thisRef.serviceSpec.Name, "status")
+	if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		return result
+	}
+
+	if strings.Contains(output, "unrecognized service") {
+		result.Error = ErrServiceDoesNotExist
+		return result
+	}
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			result.Error = err
+			return result
+		}
+
+		exitCode = exitErr.ExitCode()
+	}
+
+	result.Status.State, result.Status.SubState = stateFromLSBExitCode(exitCode)
+	result.IsRunning = (result.Status.State == StateActive)
+
+	// the generated init scripts keep the pid in `/var/run/<name>.pid`
+	if result.IsRunning {
+		result.PID = readPIDFile(filepath.Join("/var/run", thisRef.serviceSpec.Name+".pid"))
+		result.Status.Since = processStartTime(result.PID)
+	}
+
+	if isEnabled, err := thisRef.IsEnabledContext(ctx); err == nil {
+		result.Status.EnabledState = maskedOrEnabledState(result.IsMasked, isEnabled)
+	}
 
 	return result
 }
 
-func (thisRef systemvService) filePath() string {
+func (thisRef systemvService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.LogsContext(context.Background(), since, limit)
+}
+
+func (thisRef systemvService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return readLogFiles(thisRef.logFilePaths(), since, limit)
+}
+
+func (thisRef systemvService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return followLogFiles(ctx, thisRef.logFilePaths()), nil
+}
+
+// logFilePaths - the script `SERVICEToSystemV()` writes always logs to `/var/log/<name>.log` and `.err`,
+// other scripts say where in `stdout_log=` and `stderr_log=`
+func (thisRef systemvService) logFilePaths() []string {
+	stdOut := "/var/log/" + thisRef.serviceSpec.Name + ".log"
+	stdErr := "/var/log/" + thisRef.serviceSpec.Name + ".err"
+
+	if !thisRef.useConfigAsFileContent {
+		// INFO: `SystemVToSERVICE()` keeps the quotes and the `$name` of `stdout_log="/var/log/$name.log"`
+		fromScript := func(value string) string {
+			return strings.Replace(strings.Trim(value, `"`), "$name", thisRef.serviceSpec.Name, -1)
+		}
+
+		if value := fromScript(thisRef.serviceSpec.Logging.StdOut.Value); len(value) > 0 {
+			stdOut = value
+		}
+		if value := fromScript(thisRef.serviceSpec.Logging.StdErr.Value); len(value) > 0 {
+			stdErr = value
+		}
+	}
+
+	if stdOut == stdErr {
+		return []string{thisRef.opts.rooted(stdOut)}
+	}
+
+	return []string{thisRef.opts.rooted(stdOut), thisRef.opts.rooted(stdErr)}
+}
+
+// scriptPath - the init script, as seen by the init system
+func (thisRef systemvService) scriptPath() string {
 	return filepath.Join("/etc/init.d/", thisRef.serviceSpec.Name)
 }
 
-func runServiceCommand(args ...string) (string, error) {
-	if !helpers.IsRoot() {
-		args = append([]string{"--user"}, args...)
+// stateFromLSBExitCode - maps the exit code of `status` to State and SubState,
+// 0 running, 1 and 2 dead but the pid or lock file exists, 3 not running, 4 and up unknown
+func stateFromLSBExitCode(exitCode int) (State, string) {
+	switch exitCode {
+	case 0:
+		return StateActive, "running"
+	case 1, 2:
+		return StateFailed, "dead"
+	case 3:
+		return StateInactive, "dead"
+	}
+
+	return StateUnknown, ""
+}
+
+func (thisRef systemvService) filePath() string {
+	return thisRef.opts.rooted(thisRef.scriptPath())
+}
+
+// rcLinks - the links `EnableContext()` creates, start in the multi-user runlevels and kill in the rest
+func (thisRef systemvService) rcLinks() []string {
+	links := []string{}
+	for _, i := range [...]string{"2", "3", "4", "5"} {
+		links = append(links, thisRef.opts.rooted("/etc/rc"+i+".d/S50"+thisRef.serviceSpec.Name))
+	}
+	for _, i := range [...]string{"0", "1", "6"} {
+		links = append(links, thisRef.opts.rooted("/etc/rc"+i+".d/K02"+thisRef.serviceSpec.Name))
+	}
+
+	return links
+}
+
+// existingRCLinks - all start and kill links for the service, whatever their priority or who created them
+func (thisRef systemvService) existingRCLinks() []string {
+	links := []string{}
+	for _, pattern := range [...]string{"/etc/rc?.d/S??", "/etc/rc?.d/K??"} {
+		matches, _ := filepath.Glob(thisRef.opts.rooted(pattern + thisRef.serviceSpec.Name))
+		links = append(links, matches...)
+	}
+
+	return links
+}
+
+// systemvLimitsScript - sets the limits before the script does anything else, whatever it starts inherits them
+func systemvLimitsScript(script string, limits Limits) string {
+	settings := shellLimitCommands(limits)
+	if len(settings) <= 0 {
+		return script
+	}
+
+	return systemvScriptPrologue(script, "# resource limits\n"+strings.Join(settings, "\n")+"\n")
+}
+
+// systemvScriptPrologue - `block` before the first command, the comments up to there are headers, ex: LSB and chkconfig ones
+func systemvScriptPrologue(script string, block string) string {
+	lines := strings.SplitAfter(script, "\n")
+	at := len(lines)
+	for i, line := range lines {
+		if trimmed := strings.TrimSpace(line); len(trimmed) > 0 && !strings.HasPrefix(trimmed, "#") {
+			at = i
+			break
+		}
+	}
+
+	result := append([]string{}, lines[:at]...)
+	if at > 0 && !strings.HasSuffix(result[at-1], "\n") {
+		result[at-1] += "\n"
+	}
+	result = append(result, block+"\n")
+	result = append(result, lines[at:]...)
+
+	return strings.Join(result, "")
+}
+
+// shellLimitCommands - `/bin/sh` commands that apply Limits to the shell and what it starts, cron jobs use them too.
+// INFO: `ulimit` has no portable flag for processes, bash and BusyBox take `-u`, dash `-p`
+func shellLimitCommands(limits Limits) []string {
+	result := []string{}
+	if limits.OpenFiles > 0 {
+		result = append(result, fmt.Sprintf("ulimit -n %d", limits.OpenFiles))
 	}
+	if limits.MemoryMax > 0 {
+		result = append(result, fmt.Sprintf("ulimit -v %d", (limits.MemoryMax+1023)/1024))
+	}
+	if limits.TasksMax > 0 {
+		result = append(result, fmt.Sprintf("{ ulimit -u %d || ulimit -p %d; } 2> /dev/null", limits.TasksMax, limits.TasksMax))
+	}
+
+	return result
+}
 
+func (thisRef systemvService) runServiceCommand(ctx context.Context, args ...string) (string, error) {
 	logging.Debugf("%s: RUN-SERVICE: service %s", logTagSystemV, strings.Join(args, " "))
 
-	output, err := helpers.ExecWithArgs("service", args...)
+	output, err := thisRef.opts.executor.Exec(ctx, "service", args...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	errAsString := ""
 	if err != nil {
 		errAsString = err.Error()
@@ -204,5 +578,5 @@ func runServiceCommand(args ...string) (string, error) {
 
 	logging.Debugf("%s: RUN-SERVICE-OUT: output: %s, error: %s", logTagSystemV, output, errAsString)
 
-	return output, err
+	return output, newOperationError(string(spec.InitSystemV), args[len(args)-1], append([]string{"service"}, args...), output, err)
 }
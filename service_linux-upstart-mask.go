@@ -0,0 +1,122 @@
+// +build linux
+
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	logging "github.com/codemodify/systemkit-logging"
+)
+
+// upstartMaskBlock - `manual` keeps start on conditions, ex: `start on started <other job>`, from starting the job
+// and the failing `pre-start` keeps `initctl start` from doing it, Upstart has no masking of its own
+var upstartMaskBlock = []string{
+	"# masked, Unmask() removes this block",
+	"manual",
+	"pre-start exec /bin/false",
+	"# end of masked",
+}
+
+func (thisRef upstartService) Mask() error {
+	return thisRef.MaskContext(context.Background())
+}
+
+// MaskContext - adds `upstartMaskBlock` to the job's `.override` file
+func (thisRef upstartService) MaskContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 1.
+	if _, err := os.Stat(thisRef.filePath()); err != nil {
+		return ErrServiceDoesNotExist
+	}
+
+	fileContent, err := ioutil.ReadFile(thisRef.overrideFilePath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if isUpstartMasked(string(fileContent)) {
+		return nil
+	}
+
+	// 2.
+	overrideContent := string(fileContent)
+	if len(overrideContent) > 0 && !strings.HasSuffix(overrideContent, "\n") {
+		overrideContent += "\n"
+	}
+	overrideContent += strings.Join(upstartMaskBlock, "\n") + "\n"
+
+	logging.Debugf("writing override to: %s", thisRef.overrideFilePath())
+	return ioutil.WriteFile(thisRef.overrideFilePath(), []byte(overrideContent), 0644)
+}
+
+func (thisRef upstartService) Unmask() error {
+	return thisRef.UnmaskContext(context.Background())
+}
+
+// UnmaskContext - drops `upstartMaskBlock` from the job's `.override` file, a `manual` from `DisableContext()` stays
+func (thisRef upstartService) UnmaskContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 1.
+	fileContent, err := ioutil.ReadFile(thisRef.overrideFilePath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !isUpstartMasked(string(fileContent)) {
+		return nil
+	}
+
+	// 2.
+	overrideContent := withoutUpstartMaskBlock(string(fileContent))
+	if len(strings.TrimSpace(overrideContent)) == 0 {
+		logging.Debugf("remove override file")
+		return os.Remove(thisRef.overrideFilePath())
+	}
+
+	logging.Debugf("writing override to: %s", thisRef.overrideFilePath())
+	return ioutil.WriteFile(thisRef.overrideFilePath(), []byte(overrideContent), 0644)
+}
+
+func (thisRef upstartService) isMasked() bool {
+	overrideContent, _ := ioutil.ReadFile(thisRef.overrideFilePath())
+	return isUpstartMasked(string(overrideContent))
+}
+
+func isUpstartMasked(overrideContent string) bool {
+	for _, line := range strings.Split(overrideContent, "\n") {
+		if line == upstartMaskBlock[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutUpstartMaskBlock - `overrideContent` without `upstartMaskBlock`, what `DisableContext()` wrote stays
+func withoutUpstartMaskBlock(overrideContent string) string {
+	lines := []string{}
+	inBlock := false
+	for _, line := range strings.Split(overrideContent, "\n") {
+		switch {
+		case line == upstartMaskBlock[0]:
+			inBlock = true
+		case inBlock && line == upstartMaskBlock[len(upstartMaskBlock)-1]:
+			inBlock = false
+		case !inBlock:
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -3,10 +3,14 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	logging "github.com/codemodify/systemkit-logging"
 	encoders "github.com/codemodify/systemkit-service-encoders-upstart"
@@ -20,41 +24,54 @@ type upstartService struct {
 	serviceSpec            spec.SERVICE
 	useConfigAsFileContent bool
 	fileContentTemplate    string
+	opts                   options
+	instance               string // of an emulated template, an `env` stanza sets `$INSTANCE`
 }
 
-func newServiceFromSERVICE_Upstart(serviceSpec spec.SERVICE) Service {
+func newServiceFromSERVICE_Upstart(serviceSpec spec.SERVICE, opts options) Service {
 	logging.Debugf("%s: serviceSpec object: %s", logTagUpstart, helpers.AsJSONString(serviceSpec))
 
 	return &upstartService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: true,
+		opts:                   opts,
 	}
 }
 
-func newServiceFromName_Upstart(name string) (Service, error) {
-	serviceFile := filepath.Join("/etc/init/", name+".conf")
+func newServiceFromName_Upstart(name string, opts options) (Service, error) {
+	serviceFile := opts.rooted(filepath.Join(upstartJobDir(opts), name+".conf"))
 
 	fileContent, err := ioutil.ReadFile(serviceFile)
 	if err != nil {
 		return nil, ErrServiceDoesNotExist
 	}
 
-	return newServiceFromPlatformTemplate_Upstart(name, string(fileContent))
+	return newServiceFromPlatformTemplate_Upstart(name, string(fileContent), opts)
 }
 
-func newServiceFromPlatformTemplate_Upstart(name string, template string) (Service, error) {
+func newServiceFromPlatformTemplate_Upstart(name string, template string, opts options) (Service, error) {
 	logging.Debugf("%s: template: %s", logTagUpstart, template)
 
 	serviceSpec := encoders.UpStartToSERVICE(template)
+	serviceSpec.Name = name
 
 	return &upstartService{
 		serviceSpec:            serviceSpec,
 		useConfigAsFileContent: false,
 		fileContentTemplate:    template,
+		opts:                   opts,
 	}, nil
 }
 
 func (thisRef upstartService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef upstartService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
 	dir := filepath.Dir(thisRef.filePath())
 
 	// 1.
@@ -70,6 +87,14 @@ func (thisRef upstartService) Install() error {
 		fileContent = thisRef.fileContentTemplate
 	}
 
+	if thisRef.opts.limits != nil {
+		fileContent = upstartLimitsJob(fileContent, *thisRef.opts.limits)
+	}
+
+	if len(thisRef.instance) > 0 {
+		fileContent = strings.TrimRight(fileContent, "\n") + "\nenv " + instanceEnvVar + "=" + thisRef.instance + "\n"
+	}
+
 	logging.Debugf("writing unit to: %s", thisRef.filePath())
 
 	err := ioutil.WriteFile(thisRef.filePath(), []byte(fileContent), 0644)
@@ -83,16 +108,29 @@ func (thisRef upstartService) Install() error {
 }
 
 func (thisRef upstartService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef upstartService) UninstallContext(ctx context.Context) error {
 	// 1.
 	logging.Debugf("%s: attempting to uninstall: %s", logTagUpstart, thisRef.serviceSpec.Name)
 
 	// 2.
-	err := thisRef.Stop()
-	if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
-		return err
+	if !thisRef.opts.isOffline() {
+		err := thisRef.StopContext(ctx)
+		if err != nil && !helpers.Is(err, ErrServiceDoesNotExist) {
+			return err
+		}
 	}
 
 	// 3.
+	logging.Debugf("remove override file")
+	err := os.Remove(thisRef.overrideFilePath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// 4.
 	logging.Debugf("remove unit file")
 	err = os.Remove(thisRef.filePath())
 	if e, ok := err.(*os.PathError); ok {
@@ -105,12 +143,32 @@ func (thisRef upstartService) Uninstall() error {
 }
 
 func (thisRef upstartService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef upstartService) StartContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.opts.enableOnStart {
+			return thisRef.EnableContext(ctx)
+		}
+
+		return thisRef.opts.errOffline("start")
+	}
+
 	// 1.
-	logging.Debugf("loading unit file with systemd")
-	output, err := runInitctlCommand("start", thisRef.serviceSpec.Name)
+	if thisRef.opts.enableOnStart {
+		err := thisRef.EnableContext(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2.
+	logging.Debugf("starting service")
+	output, err := thisRef.runInitctlCommand(ctx, "start", thisRef.serviceSpec.Name)
 	if err != nil {
 		if strings.Contains(output, "Failed to start") && strings.Contains(output, "not found") {
-			return ErrServiceDoesNotExist
+			return reclassify(err, ErrServiceDoesNotExist)
 		}
 
 		return err
@@ -120,12 +178,55 @@ func (thisRef upstartService) Start() error {
 }
 
 func (thisRef upstartService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef upstartService) StopContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		if thisRef.opts.enableOnStart {
+			return thisRef.DisableContext(ctx)
+		}
+
+		return thisRef.opts.errOffline("stop")
+	}
+
 	// 1.
 	logging.Debugf("stopping service")
-	output, err := runInitctlCommand("stop", thisRef.serviceSpec.Name)
+	output, err := thisRef.runInitctlCommand(ctx, "stop", thisRef.serviceSpec.Name)
 	if err != nil {
 		if strings.Contains(output, "Failed to stop") && strings.Contains(output, "not loaded") {
-			return ErrServiceDoesNotExist
+			return reclassify(err, ErrServiceDoesNotExist)
+		}
+
+		return err
+	}
+
+	// 2.
+	if thisRef.opts.enableOnStart {
+		return thisRef.DisableContext(ctx)
+	}
+
+	return nil
+}
+
+func (thisRef upstartService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef upstartService) RestartContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("restart")
+	}
+
+	// 1.
+	logging.Debugf("restarting service")
+	output, err := thisRef.runInitctlCommand(ctx, "restart", thisRef.serviceSpec.Name)
+	if err != nil {
+		if strings.Contains(output, "Unknown job") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		} else if strings.Contains(output, "Unknown instance") {
+			// `initctl restart` only works on a running job
+			return thisRef.StartContext(ctx)
 		}
 
 		return err
@@ -134,7 +235,134 @@ func (thisRef upstartService) Stop() error {
 	return nil
 }
 
+func (thisRef upstartService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+func (thisRef upstartService) ReloadContext(ctx context.Context) error {
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("reload")
+	}
+
+	// 1.
+	logging.Debugf("reloading service")
+	output, err := thisRef.runInitctlCommand(ctx, "reload", thisRef.serviceSpec.Name)
+	if err != nil {
+		if strings.Contains(output, "Unknown job") {
+			return reclassify(err, ErrServiceDoesNotExist)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (thisRef upstartService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+// EnableContext - drops the `manual` stanza from the job's `.override` file, so its `start on` condition applies again
+func (thisRef upstartService) EnableContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.isMasked() {
+		return errMasked(thisRef.serviceSpec.Name)
+	}
+
+	// 1.
+	fileContent, err := ioutil.ReadFile(thisRef.overrideFilePath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// 2.
+	lines := []string{}
+	for _, line := range strings.Split(string(fileContent), "\n") {
+		if strings.TrimSpace(line) != "manual" {
+			lines = append(lines, line)
+		}
+	}
+
+	overrideContent := strings.Join(lines, "\n")
+	if len(strings.TrimSpace(overrideContent)) == 0 {
+		logging.Debugf("remove override file")
+		return os.Remove(thisRef.overrideFilePath())
+	}
+
+	logging.Debugf("writing override to: %s", thisRef.overrideFilePath())
+	return ioutil.WriteFile(thisRef.overrideFilePath(), []byte(overrideContent), 0644)
+}
+
+func (thisRef upstartService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+// DisableContext - adds the `manual` stanza to the job's `.override` file, the job can still be started by hand
+func (thisRef upstartService) DisableContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// 1.
+	fileContent, err := ioutil.ReadFile(thisRef.overrideFilePath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// INFO: the `manual` in a mask goes away with `UnmaskContext()`, it doesn't count
+	if hasManualStanza(withoutUpstartMaskBlock(string(fileContent))) {
+		return nil
+	}
+
+	// 2.
+	overrideContent := string(fileContent)
+	if len(overrideContent) > 0 && !strings.HasSuffix(overrideContent, "\n") {
+		overrideContent += "\n"
+	}
+	overrideContent += "manual\n"
+
+	logging.Debugf("writing override to: %s", thisRef.overrideFilePath())
+	return ioutil.WriteFile(thisRef.overrideFilePath(), []byte(overrideContent), 0644)
+}
+
+func (thisRef upstartService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef upstartService) IsEnabledContext(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	fileContent, err := ioutil.ReadFile(thisRef.filePath())
+	if err != nil {
+		return false, ErrServiceDoesNotExist
+	}
+
+	overrideContent, _ := ioutil.ReadFile(thisRef.overrideFilePath())
+
+	// a job starts at boot only if it has a `start on` condition that is not overridden by `manual`
+	hasStartOn := false
+	for _, line := range strings.Split(string(fileContent), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "start on") {
+			hasStartOn = true
+			break
+		}
+	}
+
+	return hasStartOn && !hasManualStanza(string(fileContent)) && !hasManualStanza(string(overrideContent)), nil
+}
+
 func (thisRef upstartService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+func (thisRef upstartService) InfoContext(ctx context.Context) Info {
 	fileContent, _ := ioutil.ReadFile(thisRef.filePath())
 
 	result := Info{
@@ -144,47 +372,217 @@ func (thisRef upstartService) Info() Info {
 		PID:         -1,
 		FilePath:    thisRef.filePath(),
 		FileContent: string(fileContent),
+		Status:      Status{State: StateUnknown},
+	}
+	result.IsMasked = thisRef.isMasked()
+
+	if thisRef.opts.isOffline() {
+		if len(fileContent) <= 0 {
+			result.Error = ErrServiceDoesNotExist
+		} else if isEnabled, err := thisRef.IsEnabledContext(ctx); err == nil {
+			result.Status.EnabledState = maskedOrEnabledState(result.IsMasked, isEnabled)
+		}
+
+		return result
+	}
+
+	// ex: `name start/running, process 1234` or `name stop/waiting`
+	output, err := thisRef.runInitctlCommand(ctx, "status", thisRef.serviceSpec.Name)
+	if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		return result
+	}
+
+	if strings.Contains(output, "Unknown job") {
+		result.Error = ErrServiceDoesNotExist
+		return result
+	}
+
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	for _, field := range strings.Fields(strings.Replace(output, ",", " ", -1)) {
+		if goalAndState := strings.Split(field, "/"); len(goalAndState) == 2 {
+			result.Status.State = stateFromGoalAndState(goalAndState[0], goalAndState[1])
+			result.Status.SubState = goalAndState[1]
+			result.IsRunning = (result.Status.State == StateActive)
+		}
+	}
+
+	if processIndex := strings.Index(output, "process "); processIndex != -1 {
+		fields := strings.Fields(output[processIndex+len("process "):])
+		if len(fields) > 0 {
+			if pid, err := strconv.Atoi(fields[0]); err == nil {
+				result.PID = pid
+				result.Status.Since = processStartTime(pid)
+			}
+		}
 	}
 
-	// output, err := runInitctlCommand("status", thisRef.serviceSpec.Name)
-	// if err != nil {
-	// 	result.Error = err
-	// 	return result
-	// }
-
-	// if strings.Contains(output, "could not be found") {
-	// 	result.Error = ErrServiceDoesNotExist
-	// 	return result
-	// }
-
-	// for _, line := range strings.Split(output, "\n") {
-	// 	if strings.Contains(line, "Main PID") {
-	// 		lineParts := strings.Split(strings.TrimSpace(line), " ")
-	// 		if len(lineParts) >= 2 {
-	// 			result.PID, _ = strconv.Atoi(lineParts[2])
-	// 		}
-	// 	} else if strings.Contains(line, "Active") {
-	// 		if strings.Contains(line, "active (running)") {
-	// 			result.IsRunning = true
-	// 		}
-	// 	}
-	// }
+	if isEnabled, err := thisRef.IsEnabledContext(ctx); err == nil {
+		result.Status.EnabledState = maskedOrEnabledState(result.IsMasked, isEnabled)
+	}
 
 	return result
 }
 
+func (thisRef upstartService) Logs(since time.Time, limit int) ([]LogLine, error) {
+	return thisRef.LogsContext(context.Background(), since, limit)
+}
+
+func (thisRef upstartService) LogsContext(ctx context.Context, since time.Time, limit int) ([]LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return readLogFiles(paths, since, limit)
+}
+
+func (thisRef upstartService) FollowLogs(ctx context.Context) (<-chan LogLine, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	paths, err := thisRef.logFilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return followLogFiles(ctx, paths), nil
+}
+
+// logFilePaths - from `SERVICE.Logging`, `UseDefault` and jobs with `console log` log where Upstart puts console output
+func (thisRef upstartService) logFilePaths() ([]string, error) {
+	paths := logFilePaths(thisRef.serviceSpec.Logging, thisRef.consoleLogPath(), "")
+	if !thisRef.useConfigAsFileContent && hasConsoleLogStanza(thisRef.fileContentTemplate) && (len(paths) <= 0 || paths[0] != thisRef.consoleLogPath()) {
+		paths = append(paths, thisRef.consoleLogPath())
+	}
+
+	if len(paths) <= 0 {
+		return nil, fmt.Errorf("%w: %s does not log to a file", ErrServiceUnsupportedRequest, thisRef.serviceSpec.Name)
+	}
+
+	for i := range paths {
+		paths[i] = thisRef.opts.rooted(paths[i])
+	}
+
+	return paths, nil
+}
+
+// consoleLogPath - where `console log` writes, session jobs log into the user's cache
+func (thisRef upstartService) consoleLogPath() string {
+	if thisRef.opts.isUserScope() {
+		return filepath.Join(helpers.HomeDir(""), ".cache/upstart", thisRef.serviceSpec.Name+".log")
+	}
+
+	return filepath.Join("/var/log/upstart", thisRef.serviceSpec.Name+".log")
+}
+
+func hasConsoleLogStanza(jobContent string) bool {
+	for _, line := range strings.Split(jobContent, "\n") {
+		if strings.Join(strings.Fields(line), " ") == "console log" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// upstartJobDir - where jobs are installed, as seen by the init system, user jobs belong to the session init
+func upstartJobDir(opts options) string {
+	if opts.isUserScope() {
+		return filepath.Join(helpers.HomeDir(""), ".config/upstart")
+	}
+
+	return "/etc/init/"
+}
+
 func (thisRef upstartService) filePath() string {
-	return filepath.Join("/etc/init/", thisRef.serviceSpec.Name+".conf")
+	return thisRef.opts.rooted(filepath.Join(upstartJobDir(thisRef.opts), thisRef.serviceSpec.Name+".conf"))
+}
+
+func (thisRef upstartService) overrideFilePath() string {
+	return thisRef.opts.rooted(filepath.Join(upstartJobDir(thisRef.opts), thisRef.serviceSpec.Name+".override"))
 }
 
-func runInitctlCommand(args ...string) (string, error) {
-	if !helpers.IsRoot() {
-		args = append([]string{"--user"}, args...)
+// stateFromGoalAndState - maps Upstart's `goal/state` pair to State, Upstart does not remember failures
+func stateFromGoalAndState(goal string, state string) State {
+	switch {
+	case goal == "start" && state == "running":
+		return StateActive
+	case goal == "start":
+		return StateActivating
+	case goal == "stop" && state == "waiting":
+		return StateInactive
+	case goal == "stop":
+		return StateDeactivating
+	}
+
+	return StateUnknown
+}
+
+// upstartLimitsJob - adds a `limit` stanza for each of the limits, soft and hard the same, replacing the ones already there
+func upstartLimitsJob(jobContent string, limits Limits) string {
+	stanzas := map[string]uint64{}
+	if limits.OpenFiles > 0 {
+		stanzas["nofile"] = limits.OpenFiles
+	}
+	if limits.TasksMax > 0 {
+		stanzas["nproc"] = limits.TasksMax
+	}
+	if limits.MemoryMax > 0 {
+		stanzas["as"] = limits.MemoryMax
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimRight(jobContent, "\n"), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "limit" {
+			if _, ok := stanzas[fields[1]]; ok {
+				continue
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	for _, resource := range []string{"nofile", "nproc", "as"} {
+		if value, ok := stanzas[resource]; ok {
+			lines = append(lines, fmt.Sprintf("limit %s %d %d", resource, value, value))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func hasManualStanza(jobContent string) bool {
+	for _, line := range strings.Split(jobContent, "\n") {
+		if strings.TrimSpace(line) == "manual" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (thisRef upstartService) runInitctlCommand(ctx context.Context, args ...string) (string, error) {
+	// INFO: `--user` is not a thing, user jobs are run by the session init, reached through the session bus
+	if thisRef.opts.isUserScope() {
+		args = append([]string{"--session"}, args...)
 	}
 
 	logging.Debugf("%s: RUN-INITCTL: initctl %s", logTagUpstart, strings.Join(args, " "))
 
-	output, err := helpers.ExecWithArgs("initctl", args...)
+	output, err := thisRef.opts.executor.Exec(ctx, "initctl", args...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	errAsString := ""
 	if err != nil {
 		errAsString = err.Error()
@@ -192,5 +590,5 @@ func runInitctlCommand(args ...string) (string, error) {
 
 	logging.Debugf("%s: RUN-INITCTL-OUT: output: %s, error: %s", logTagUpstart, output, errAsString)
 
-	return output, err
+	return output, newOperationError(string(spec.InitUpstart), operationFromArgs(args), append([]string{"initctl"}, args...), output, err)
 }
@@ -3,10 +3,14 @@
 package service
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	logging "github.com/codemodify/systemkit-logging"
 	spec "github.com/codemodify/systemkit-service-spec"
@@ -14,78 +18,210 @@ import (
 
 var logTag = "LINUX-SERVICE"
 
-func newServiceFromSERVICE(serviceSpec spec.SERVICE) Service {
-	switch getInitType() {
+// initTypeEnvVar - overrides the detected init system, ex: in containers where PID 1 is not the init system
+const initTypeEnvVar = "SYSTEMKIT_SERVICE_INIT"
+
+func newServiceFromSERVICE(serviceSpec spec.SERVICE, opts options) (Service, error) {
+	initType, err := initTypeFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = opts.forInitType(initType)
+	if err := opts.validateFor(initType); err != nil {
+		return nil, err
+	}
+
+	// INFO: SysV and Upstart have no templates, each instance gets its own script, job or cron entry
+	if isTemplateName(serviceSpec.Name) && isSystemVOrUpstart(initType) {
+		return newEmulatedTemplateFromSERVICE(initType, serviceSpec, opts), nil
+	}
+
+	// INFO: SysV and Upstart have no timers, cron runs scheduled services there
+	if opts.schedule != nil && isSystemVOrUpstart(initType) {
+		return newServiceFromSERVICE_Cron(serviceSpec, opts), nil
+	}
+
+	switch initType {
 	case spec.InitSystemV:
-		return newServiceFromSERVICE_SystemV(serviceSpec)
+		return newServiceFromSERVICE_SystemV(serviceSpec, opts), nil
 	case spec.InitSystemd:
-		return newServiceFromSERVICE_SystemD(serviceSpec)
+		return newServiceFromSERVICE_SystemD(serviceSpec, opts), nil
 	case spec.InitUpstart:
-		return newServiceFromSERVICE_Upstart(serviceSpec)
+		return newServiceFromSERVICE_Upstart(serviceSpec, opts), nil
 	default:
 	}
 
-	return nil
+	return nil, errInitTypeNotSupported(initType)
 }
 
-func newServiceFromName(name string) (Service, error) {
-	switch getInitType() {
+func newServiceFromName(name string, opts options) (Service, error) {
+	initType, err := initTypeFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = opts.forInitType(initType)
+	if err := opts.validateFor(initType); err != nil {
+		return nil, err
+	}
+
+	if isTemplateName(name) && isSystemVOrUpstart(initType) {
+		return newEmulatedTemplateFromName(initType, name, opts), nil
+	}
+
+	// INFO: SysV and Upstart have no timers, cron runs scheduled services there
+	if opts.schedule != nil && isSystemVOrUpstart(initType) {
+		return newServiceFromName_Cron(name, opts)
+	}
+
+	switch initType {
 	case spec.InitSystemV:
-		return newServiceFromName_SystemV(name)
+		return newServiceFromName_SystemV(name, opts)
 	case spec.InitSystemd:
-		return newServiceFromName_SystemD(name)
+		return newServiceFromName_SystemD(name, opts)
 	case spec.InitUpstart:
-		return newServiceFromName_Upstart(name)
+		return newServiceFromName_Upstart(name, opts)
 	default:
 	}
 
-	return nil, nil
+	return nil, errInitTypeNotSupported(initType)
 }
 
-func newServiceFromPlatformTemplate(name string, template string) (Service, error) {
-	switch getInitType() {
+func newServiceFromPlatformTemplate(name string, template string, opts options) (Service, error) {
+	initType, err := initTypeFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = opts.forInitType(initType)
+	if err := opts.validateFor(initType); err != nil {
+		return nil, err
+	}
+
+	// INFO: SysV and Upstart have no timers, cron runs scheduled services there
+	if opts.schedule != nil && initType != spec.InitSystemd {
+		return nil, ErrServiceUnsupportedRequest
+	}
+
+	if isTemplateName(name) && isSystemVOrUpstart(initType) {
+		return newEmulatedTemplateFromPlatformTemplate(initType, name, template, opts), nil
+	}
+
+	switch initType {
 	case spec.InitSystemV:
-		return newServiceFromPlatformTemplate_SystemV(name, template)
+		return newServiceFromPlatformTemplate_SystemV(name, template, opts)
 	case spec.InitSystemd:
-		return newServiceFromPlatformTemplate_SystemD(name, template)
+		return newServiceFromPlatformTemplate_SystemD(name, template, opts)
 	case spec.InitUpstart:
-		return newServiceFromPlatformTemplate_Upstart(name, template)
+		return newServiceFromPlatformTemplate_Upstart(name, template, opts)
 	default:
 	}
 
-	return nil, nil
+	return nil, errInitTypeNotSupported(initType)
 }
 
-func getInitType() spec.InitType {
-	initBinary, err := ioutil.ReadFile("/proc/1/cmdline")
+func runTransient(ctx context.Context, serviceSpec spec.SERVICE, opts options) (Service, error) {
+	initType, err := initTypeFor(opts)
 	if err != nil {
-		logging.Errorf("%s: can't find underlying system service framework, error: %s", logTag, err.Error())
-		return spec.InitUknown
-	}
-
-	// trim any nul bytes, this is present with some kernels
-	init := string(bytes.TrimRight(initBinary, "\x00"))
-	if strings.Contains(init, "init [") {
-		return spec.InitSystemV
-	}
-	if strings.Contains(init, "systemd") {
-		return spec.InitSystemd
-	}
-	if strings.Contains(init, "init") {
-		// not so fast! you may think this is upstart, but it may be
-		// a symlink to systemd... yeah, debian does that... ( x )
-		var target string
-		if len(init) > 9 && init[0:10] == "/sbin/init" {
-			target, err = filepath.EvalSymlinks("/sbin/init")
-		} else {
-			target, err = filepath.EvalSymlinks(init)
-		}
-		if err == nil && strings.Contains(target, "systemd") {
-			return spec.InitSystemd
+		return nil, err
+	}
+
+	opts = opts.forInitType(initType)
+	if err := opts.validateFor(initType); err != nil {
+		return nil, err
+	}
+
+	if initType != spec.InitSystemd {
+		return nil, fmt.Errorf("%w: transient services need systemd, not %s", ErrServiceUnsupportedRequest, initType)
+	}
+
+	// INFO: these set up units next to the service or outlive it, `WithNotify()` and `WithLimits()` become unit properties
+	if opts.socket != nil || opts.schedule != nil || opts.linger {
+		return nil, fmt.Errorf("%w: transient services can't be socket-activated, scheduled or lingering", ErrServiceUnsupportedRequest)
+	}
+
+	return runTransient_SystemD(ctx, serviceSpec, opts)
+}
+
+// initTypeFor - `WithInitType()` wins over the environment, which wins over detection
+func initTypeFor(opts options) (spec.InitType, error) {
+	if len(opts.initType) > 0 {
+		return opts.initType, nil
+	}
+
+	if fromEnv := strings.TrimSpace(os.Getenv(initTypeEnvVar)); len(fromEnv) > 0 {
+		logging.Debugf("%s: init system from %s: %s", logTag, initTypeEnvVar, fromEnv)
+
+		for _, initType := range [...]spec.InitType{spec.InitSystemd, spec.InitSystemV, spec.InitUpstart, InitOpenRC, InitRunit, InitBusybox} {
+			if spec.InitType(fromEnv) == initType {
+				return initType, nil
+			}
 		}
 
-		return spec.InitUpstart
+		return spec.InitUknown, fmt.Errorf("%w: %s=%s is not an init system, use one of: %s, %s, %s", ErrServiceConfigError, initTypeEnvVar, fromEnv, spec.InitSystemd, spec.InitSystemV, spec.InitUpstart)
+	}
+
+	report := InitSystemReport{}
+	return detectInitType(context.Background(), "/", opts.executor, &report), nil
+}
+
+// isSystemVOrUpstart - the backends that emulate templates and timers
+func isSystemVOrUpstart(initType spec.InitType) bool {
+	return initType == spec.InitSystemV || initType == spec.InitUpstart
+}
+
+// errInitTypeNotSupported - the init system was found but there is no backend for it, ex: OpenRC or runit
+func errInitTypeNotSupported(initType spec.InitType) error {
+	if len(initType) <= 0 || initType == spec.InitUknown {
+		return ErrInitSystemNotDetected
+	}
+
+	return fmt.Errorf("%w: %s is not supported", ErrServiceUnsupportedRequest, initType)
+}
+
+func readPIDFile(pidFile string) int {
+	fileContent, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return -1
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(fileContent)))
+	if err != nil {
+		return -1
+	}
+
+	return pid
+}
+
+// processStartTime - the kernel creates `/proc/<pid>` when the process starts, its timestamp is close enough
+func processStartTime(pid int) *time.Time {
+	if pid <= 0 {
+		return nil
+	}
+
+	procInfo, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	if err != nil {
+		return nil
+	}
+
+	startTime := procInfo.ModTime()
+	return &startTime
+}
+
+// maskedOrEnabledState - `EnabledState` for backends that mask by hand, masked wins over enabled or not
+func maskedOrEnabledState(isMasked bool, isEnabled bool) string {
+	if isMasked {
+		return "masked"
 	}
-	// failed to detect init system, falling back to sysvinit
-	return spec.InitSystemV
+
+	return enabledStateAsString(isEnabled)
+}
+
+func enabledStateAsString(isEnabled bool) string {
+	if isEnabled {
+		return "enabled"
+	}
+
+	return "disabled"
 }
@@ -3,7 +3,7 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -53,19 +53,34 @@ type serviceError struct {
 
 type windowsService struct {
 	serviceSpec spec.SERVICE
+	opts        options
 }
 
-func newServiceFromSERVICE(serviceSpec spec.SERVICE) Service {
+func newServiceFromSERVICE(serviceSpec spec.SERVICE, opts options) (Service, error) {
+	if err := opts.validateFor(InitSCM); err != nil {
+		return nil, err
+	}
+
 	logging.Debugf("%s: serviceSpec object: %s", logTag, helpers.AsJSONString(serviceSpec))
 
 	return &windowsService{
 		serviceSpec: serviceSpec,
-	}
+		opts:        opts,
+	}, nil
+}
+
+func detectInitSystem(ctx context.Context, root string, executor Executor) InitSystemReport {
+	return InitSystemReport{Type: InitSCM, Evidence: []string{"the Service Control Manager is the only init system on windows"}}
 }
 
-func newServiceFromName(name string) (Service, error) {
+func newServiceFromName(name string, opts options) (Service, error) {
+	if err := opts.validateFor(InitSCM); err != nil {
+		return nil, err
+	}
+
 	// quick fire
-	info := newServiceFromSERVICE(spec.SERVICE{Name: name}).Info()
+	probe := &windowsService{serviceSpec: spec.SERVICE{Name: name}, opts: opts}
+	info := probe.Info()
 	if helpers.Is(info.Error, ErrServiceDoesNotExist) {
 		return nil, ErrServiceDoesNotExist
 	}
@@ -74,9 +89,9 @@ func newServiceFromName(name string) (Service, error) {
 	// wmic service "systemkit-test-service" get c
 	serviceSpec := spec.SERVICE{
 		Name:        name,
-		Description: runWmicCommand("service", fmt.Sprintf("'%s'", name), "get", "Description"),
+		Description: probe.runWmicCommand(context.Background(), "service", fmt.Sprintf("'%s'", name), "get", "Description"),
 		// Documentation: "",
-		Executable: runWmicCommand("service", fmt.Sprintf("'%s'", name), "get", "PathName"),
+		Executable: probe.runWmicCommand(context.Background(), "service", fmt.Sprintf("'%s'", name), "get", "PathName"),
 		// Args:               "",
 		// WorkingDirectory:   "",
 		// Environment:        "",
@@ -97,14 +112,35 @@ func newServiceFromName(name string) (Service, error) {
 		}
 	}
 
-	return newServiceFromSERVICE(serviceSpec), nil
+	return newServiceFromSERVICE(serviceSpec, opts)
+}
+
+func newServiceFromPlatformTemplate(name string, template string, opts options) (Service, error) {
+	if err := opts.validateFor(InitSCM); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrServiceUnsupportedRequest
 }
 
-func newServiceFromPlatformTemplate(name string, template string) (Service, error) {
+// runTransient - transient services are a systemd feature
+func runTransient(ctx context.Context, serviceSpec spec.SERVICE, opts options) (Service, error) {
 	return nil, ErrServiceUnsupportedRequest
 }
 
 func (thisRef *windowsService) Install() error {
+	return thisRef.InstallContext(context.Background())
+}
+
+func (thisRef *windowsService) InstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("install")
+	}
+
 	logging.Debugf("%s: attempting to install: %s", logTag, thisRef.serviceSpec.Name)
 
 	// 1. check if service exists
@@ -207,6 +243,18 @@ func (thisRef *windowsService) Install() error {
 }
 
 func (thisRef *windowsService) Uninstall() error {
+	return thisRef.UninstallContext(context.Background())
+}
+
+func (thisRef *windowsService) UninstallContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("uninstall")
+	}
+
 	// 1.
 	logging.Debugf("%s: attempting to uninstall: %s", logTag, thisRef.serviceSpec.Name)
 
@@ -233,6 +281,18 @@ func (thisRef *windowsService) Uninstall() error {
 }
 
 func (thisRef *windowsService) Start() error {
+	return thisRef.StartContext(context.Background())
+}
+
+func (thisRef *windowsService) StartContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("start")
+	}
+
 	// 1.
 	logging.Debugf("%s: attempting to start: %s", logTag, thisRef.serviceSpec.Name)
 
@@ -270,6 +330,18 @@ func (thisRef *windowsService) Start() error {
 }
 
 func (thisRef *windowsService) Stop() error {
+	return thisRef.StopContext(context.Background())
+}
+
+func (thisRef *windowsService) StopContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("stop")
+	}
+
 	// 1.
 	logging.Debugf("%s: attempting to stop: %s", logTag, thisRef.serviceSpec.Name)
 
@@ -282,7 +354,7 @@ func (thisRef *windowsService) Stop() error {
 	}
 
 	// 2.
-	err := thisRef.control(svc.Stop, svc.Stopped)
+	err := thisRef.control(ctx, svc.Stop, svc.Stopped)
 	if err != nil {
 		e := err.Error()
 		if strings.Contains(e, "service does not exist") {
@@ -308,10 +380,14 @@ func (thisRef *windowsService) Stop() error {
 		logging.Debugf("%s: waiting for service to stop", logTag)
 
 		// Wait a few seconds before retrying
-		time.Sleep(wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
 
 		// Attempt to stop the service again
-		info := thisRef.Info()
+		info := thisRef.InfoContext(ctx)
 		if info.Error != nil {
 			if strings.Contains(info.Error.Error(), "the pipe has been ended") {
 				info.IsRunning = false
@@ -326,7 +402,7 @@ func (thisRef *windowsService) Stop() error {
 		}
 
 		if attempt == maxAttempts {
-			return errors.New("could not stop system service after multiple attempts")
+			return fmt.Errorf("could not stop system service after multiple attempts: %w", ErrServiceTimeout)
 		}
 	}
 
@@ -335,12 +411,103 @@ func (thisRef *windowsService) Stop() error {
 	return nil
 }
 
+func (thisRef *windowsService) Restart() error {
+	return thisRef.RestartContext(context.Background())
+}
+
+func (thisRef *windowsService) RestartContext(ctx context.Context) error {
+	err := thisRef.StopContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return thisRef.StartContext(ctx)
+}
+
+func (thisRef *windowsService) Reload() error {
+	return thisRef.ReloadContext(context.Background())
+}
+
+// ReloadContext - the Service Control Manager has no notion of reloading a service
+func (thisRef *windowsService) ReloadContext(ctx context.Context) error {
+	return ErrServiceUnsupportedRequest
+}
+
+func (thisRef *windowsService) Enable() error {
+	return thisRef.EnableContext(context.Background())
+}
+
+// EnableContext - switches the service to automatic start
+func (thisRef *windowsService) EnableContext(ctx context.Context) error {
+	return thisRef.setStartType(ctx, svcMgr.StartAutomatic)
+}
+
+func (thisRef *windowsService) Disable() error {
+	return thisRef.DisableContext(context.Background())
+}
+
+// DisableContext - switches the service to manual start, it can still be started on demand
+func (thisRef *windowsService) DisableContext(ctx context.Context) error {
+	return thisRef.setStartType(ctx, svcMgr.StartManual)
+}
+
+func (thisRef *windowsService) IsEnabled() (bool, error) {
+	return thisRef.IsEnabledContext(context.Background())
+}
+
+func (thisRef *windowsService) IsEnabledContext(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if thisRef.opts.isOffline() {
+		return false, thisRef.opts.errOffline("query")
+	}
+
+	winServiceManager, winService, sError := connectAndOpenService(thisRef.serviceSpec.Name)
+	if sError.Type != serviceErrorSuccess {
+		if winServiceManager != nil {
+			winServiceManager.Disconnect()
+		}
+
+		if sError.Type == serviceErrorDoesNotExist {
+			return false, ErrServiceDoesNotExist
+		}
+
+		return false, sError.Error
+	}
+	defer winServiceManager.Disconnect()
+	defer winService.Close()
+
+	config, err := winService.Config()
+	if err != nil {
+		return false, err
+	}
+
+	return config.StartType == svcMgr.StartAutomatic, nil
+}
+
 func (thisRef *windowsService) Info() Info {
+	return thisRef.InfoContext(context.Background())
+}
+
+func (thisRef *windowsService) InfoContext(ctx context.Context) Info {
 	result := Info{
 		Error:     nil,
 		Service:   thisRef.serviceSpec,
 		IsRunning: false,
 		PID:       -1,
+		Status:    Status{State: StateUnknown},
+	}
+
+	if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		return result
+	}
+
+	if thisRef.opts.isOffline() {
+		result.Error = thisRef.opts.errOffline("query")
+		return result
 	}
 
 	// 1.
@@ -383,10 +550,35 @@ func (thisRef *windowsService) Info() Info {
 		result.PID = -1
 	}
 
+	switch stat.State {
+	case svc.Running, svc.Paused:
+		result.Status.State = StateActive
+	case svc.StartPending, svc.ContinuePending:
+		result.Status.State = StateActivating
+	case svc.StopPending, svc.PausePending:
+		result.Status.State = StateDeactivating
+	case svc.Stopped:
+		result.Status.State = StateInactive
+		if stat.Win32ExitCode != 0 {
+			result.Status.State = StateFailed
+		}
+	default:
+		result.Status.State = StateUnknown
+	}
+
+	result.Status.ExitCode = int(stat.Win32ExitCode)
+
+	if isEnabled, err := thisRef.IsEnabledContext(ctx); err == nil {
+		result.Status.EnabledState = "disabled"
+		if isEnabled {
+			result.Status.EnabledState = "enabled"
+		}
+	}
+
 	return result
 }
 
-func (thisRef *windowsService) control(serviceSpec svc.Cmd, state svc.State) error {
+func (thisRef *windowsService) control(ctx context.Context, serviceSpec svc.Cmd, state svc.State) error {
 	logging.Debugf("%s: attempting to control: %s, cmd: %v", logTag, thisRef.serviceSpec.Name, serviceSpec)
 
 	winServiceManager, winService, err := connectAndOpenService(thisRef.serviceSpec.Name)
@@ -409,10 +601,14 @@ func (thisRef *windowsService) control(serviceSpec svc.Cmd, state svc.State) err
 		if timeout.Before(time.Now()) {
 			logging.Errorf("%s: timeout waiting for service to go to state=%d", logTag, state)
 
-			return fmt.Errorf("timeout waiting for service to go to state=%d", state)
+			return fmt.Errorf("timeout waiting for service to go to state=%d: %w", state, ErrServiceTimeout)
 		}
 
-		time.Sleep(300 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(300 * time.Millisecond):
+		}
 
 		// Make sure transition happens to the desired state
 		status, err1 = winService.Query()
@@ -426,6 +622,42 @@ func (thisRef *windowsService) control(serviceSpec svc.Cmd, state svc.State) err
 	return nil
 }
 
+func (thisRef *windowsService) setStartType(ctx context.Context, startType uint32) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if thisRef.opts.isOffline() {
+		return thisRef.opts.errOffline("configure")
+	}
+
+	logging.Debugf("%s: setting start type: %s, type: %d", logTag, thisRef.serviceSpec.Name, startType)
+
+	winServiceManager, winService, sError := connectAndOpenService(thisRef.serviceSpec.Name)
+	if sError.Type != serviceErrorSuccess {
+		if winServiceManager != nil {
+			winServiceManager.Disconnect()
+		}
+
+		if sError.Type == serviceErrorDoesNotExist {
+			return ErrServiceDoesNotExist
+		}
+
+		return sError.Error
+	}
+	defer winServiceManager.Disconnect()
+	defer winService.Close()
+
+	config, err := winService.Config()
+	if err != nil {
+		return err
+	}
+
+	config.StartType = startType
+
+	return winService.UpdateConfig(config)
+}
+
 func connectAndOpenService(serviceName string) (*svcMgr.Mgr, *svcMgr.Service, serviceError) {
 	// 1.
 	logging.Debugf("%s: connecting to Windows Service Manager", logTag)
@@ -457,7 +689,7 @@ func (thisRef *windowsService) Exists() bool {
 	// https://www.computerhope.com/sc-serviceSpec.htm
 	logging.Debugf("%s: running: 'sc %s'", logTag, strings.Join(args, " "))
 
-	_, err := helpers.ExecWithArgs("sc", args...)
+	_, err := thisRef.opts.executor.Exec(context.Background(), "sc", args...)
 	if err != nil {
 		logging.Errorf("%s: error when checking %s", logTag, err)
 		return false
@@ -466,12 +698,12 @@ func (thisRef *windowsService) Exists() bool {
 	return true
 }
 
-func runWmicCommand(args ...string) string {
+func (thisRef *windowsService) runWmicCommand(ctx context.Context, args ...string) string {
 	// wmic service "systemkit-test-service" get PathName
 
 	logging.Debugf("%s: RUN-WMIC: wmic %s", logTag, strings.Join(args, " "))
 
-	output, err := helpers.ExecWithArgs("wmic", args...)
+	output, err := thisRef.opts.executor.Exec(ctx, "wmic", args...)
 	errAsString := ""
 	if err != nil {
 		errAsString = err.Error()